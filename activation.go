@@ -0,0 +1,175 @@
+package httpdump
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// ActivationMatcher reports whether request is matched by activation.
+type ActivationMatcher func(r *http.Request) bool
+
+// Activation enables dumping of matching requests until it expires,
+// see Middleware.Activate.
+type Activation struct {
+	ID      string
+	Name    string
+	Match   ActivationMatcher
+	Expires time.Time
+}
+
+// MatchHeader creates a new matcher that matches requests with specified header value.
+func MatchHeader(name, value string) ActivationMatcher {
+	return func(r *http.Request) bool {
+		return slices.Contains(r.Header.Values(name), value)
+	}
+}
+
+// MatchQuery creates a new matcher that matches requests with specified query parameter value.
+func MatchQuery(name, value string) ActivationMatcher {
+	return func(r *http.Request) bool {
+		return slices.Contains(r.URL.Query()[name], value)
+	}
+}
+
+// MatchClientIP creates a new matcher that matches requests from specified
+// IP addresses or CIDR ranges. Client address is taken from http.Request.RemoteAddr,
+// so requests behind proxies should be matched by header.
+func MatchClientIP(addrs ...string) (ActivationMatcher, error) {
+	prefixes := make([]netip.Prefix, 0, len(addrs))
+
+	for _, a := range addrs {
+		p, err := netip.ParsePrefix(a)
+		if err != nil {
+			ip, ierr := netip.ParseAddr(a)
+			if ierr != nil {
+				return nil, err
+			}
+			p = netip.PrefixFrom(ip, ip.BitLen())
+		}
+
+		prefixes = append(prefixes, p)
+	}
+
+	return func(r *http.Request) bool {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		ip, err := netip.ParseAddr(host)
+		if err != nil {
+			return false
+		}
+		ip = ip.Unmap()
+
+		for _, p := range prefixes {
+			if p.Contains(ip) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// MatchUser creates a new matcher that matches requests of specified users,
+// user id is extracted from request by userID callback.
+func MatchUser(userID func(r *http.Request) string, ids ...string) ActivationMatcher {
+	return func(r *http.Request) bool {
+		id := userID(r)
+		return id != "" && slices.Contains(ids, id)
+	}
+}
+
+// Activate enables dumping of requests matched by match for ttl, name describes
+// activation in the list. Matching requests and their responses are dumped with bodies
+// regardless of filters, excluded paths and sampling, even while middleware is disabled.
+// Several activations can be active at once. Safe to call from multiple goroutines.
+func (m *Middleware) Activate(name string, ttl time.Duration, match ActivationMatcher) Activation {
+	a := Activation{
+		ID:      newActivationID(),
+		Name:    name,
+		Match:   match,
+		Expires: time.Now().Add(ttl),
+	}
+
+	m.updateConfig(func(c *config) {
+		c.activations = append(activeOnly(c.activations, time.Now()), a)
+	})
+
+	return a
+}
+
+// Activations returns activations that are not expired yet.
+// Safe to call from multiple goroutines.
+func (m *Middleware) Activations() []Activation {
+	return activeOnly(m.config.Load().activations, time.Now())
+}
+
+// Revoke removes activation with specified id,
+// it reports false if there is no such activation.
+// Safe to call from multiple goroutines.
+func (m *Middleware) Revoke(id string) bool {
+	found := false
+
+	m.updateConfig(func(c *config) {
+		c.activations = slices.DeleteFunc(c.activations, func(a Activation) bool {
+			if a.ID == id {
+				found = true
+				return true
+			}
+			return false
+		})
+	})
+
+	return found
+}
+
+// ActivationID returns id of activation that matched dumped request,
+// it returns empty string if request is dumped without activation.
+func ActivationID(r *http.Request) string {
+	st := stateFromRequest(r)
+	if st == nil {
+		return ""
+	}
+
+	return st.activation
+}
+
+// activation returns id of the first active activation that matches request.
+func (c *config) activation(r *http.Request, now time.Time) string {
+	for _, a := range c.activations {
+		if now.Before(a.Expires) && a.Match(r) {
+			return a.ID
+		}
+	}
+
+	return ""
+}
+
+func activated(r *http.Request) bool {
+	st := stateFromRequest(r)
+	return st != nil && st.activation != ""
+}
+
+func activeOnly(activations []Activation, now time.Time) []Activation {
+	active := make([]Activation, 0, len(activations))
+	for _, a := range activations {
+		if now.Before(a.Expires) {
+			active = append(active, a)
+		}
+	}
+
+	return active
+}
+
+func newActivationID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,145 @@
+package httpdump_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_Activate(t *testing.T) {
+	var activation string
+
+	m, dump := newMiddleware(true, true, nil)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		activation = httpdump.ActivationID(r)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("response body"))
+	}))
+
+	noerr(t, m.UpdateSettings(func(s *httpdump.Settings) {
+		s.SampleRate = 0
+	}))
+
+	serve := func(p, remoteAddr string, headers ...string) {
+		dump.reqDumped, dump.respDumped = false, false
+		dump.reqBody, dump.respBody = nil, nil
+
+		req := httptest.NewRequest(http.MethodPost, p, strings.NewReader("request body"))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.RemoteAddr = remoteAddr
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	ipMatch, err := httpdump.MatchClientIP("10.0.0.0/8", "192.168.1.1")
+	noerr(t, err)
+
+	byIP := m.Activate("office", time.Minute, ipMatch)
+	byUser := m.Activate("user", time.Minute, httpdump.MatchUser(func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	}, "alice"))
+	m.Activate("expired", -time.Second, httpdump.MatchQuery("debug", "1"))
+
+	if a := m.Activations(); len(a) != 2 || a[0].ID != byIP.ID || a[1].ID != byUser.ID {
+		t.Fatalf("Unexpected activations %+v", a)
+	}
+
+	tests := []struct {
+		path       string
+		remoteAddr string
+		headers    []string
+		activation string
+	}{
+		{"/", "10.1.2.3:1234", nil, byIP.ID},
+		{"/", "192.168.1.1:1234", nil, byIP.ID},
+		{"/", "192.168.1.2:1234", []string{"X-User", "alice"}, byUser.ID},
+		{"/", "192.168.1.2:1234", []string{"X-User", "bob"}, ""},
+		{"/?debug=1", "192.168.1.2:1234", nil, ""},
+	}
+
+	for _, tt := range tests {
+		serve(tt.path, tt.remoteAddr, tt.headers...)
+
+		if activation != tt.activation {
+			t.Errorf("Expected request from %s to be activated by %q, got %q", tt.remoteAddr, tt.activation, activation)
+		}
+
+		activated := tt.activation != ""
+
+		if dump.reqDumped != activated {
+			t.Errorf("Expected request from %s to be dumped: %v", tt.remoteAddr, activated)
+		}
+
+		if activated && (string(dump.reqBody) != "request body" || string(dump.respBody) != "response body") {
+			t.Errorf("Expected activated request to be dumped with bodies, got %q, %q", dump.reqBody, dump.respBody)
+		}
+	}
+
+	if !m.Revoke(byIP.ID) || m.Revoke(byIP.ID) {
+		t.Errorf("Expected activation to be revoked once")
+	}
+
+	serve("/", "10.1.2.3:1234")
+
+	if dump.reqDumped {
+		t.Errorf("Expected request not to be dumped after activation is revoked")
+	}
+
+	if a := m.Activations(); len(a) != 1 || a[0].ID != byUser.ID {
+		t.Errorf("Unexpected activations %+v", a)
+	}
+}
+
+func TestMiddleware_ActivateDisabled(t *testing.T) {
+	m, dump := newMiddleware(true, true, nil)
+	m.SetEnabled(false)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("response body"))
+	}))
+
+	a := m.Activate("debug", time.Minute, httpdump.MatchHeader("X-Debug", "1"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if dump.reqDumped || dump.respDumped {
+		t.Errorf("Expected request not to be dumped while middleware is disabled")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Debug", "1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !dump.reqDumped || !dump.respDumped || string(dump.respBody) != "response body" {
+		t.Errorf("Expected activated request to be dumped while middleware is disabled")
+	}
+
+	if id := httpdump.ActivationID(dump.req); id != a.ID {
+		t.Errorf("Expected request to be activated by %q, got %q", a.ID, id)
+	}
+}
+
+func TestMatchHeader(t *testing.T) {
+	match := httpdump.MatchHeader("X-Trace", "abc")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("X-Trace", "def")
+	req.Header.Add("X-Trace", "abc")
+
+	if !match(req) {
+		t.Errorf("Expected request to match header")
+	}
+
+	if _, err := httpdump.MatchClientIP("not an ip"); err == nil {
+		t.Errorf("Expected invalid address to be rejected")
+	}
+}
@@ -0,0 +1,130 @@
+// Package admin provides http handler that shows and changes
+// httpdump middleware settings at runtime. It is intended to be
+// served on internal admin port only.
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/hummerd/httpdump"
+)
+
+// State is a middleware state returned by handler.
+type State struct {
+	Enabled       bool     `json:"enabled"`
+	BodyLimit     int      `json:"body_limit"`
+	ExcludedPaths []string `json:"excluded_paths"`
+	SampleRate    float64  `json:"sample_rate"`
+	Queued        int      `json:"queued"`
+	Dropped       uint64   `json:"dropped"`
+	Filtered      uint64   `json:"filtered"`
+}
+
+// Update is a settings change accepted by handler, only specified fields are changed.
+// Excluded paths are removed before new paths are added.
+type Update struct {
+	Enabled             *bool    `json:"enabled,omitempty"`
+	BodyLimit           *int     `json:"body_limit,omitempty"`
+	SampleRate          *float64 `json:"sample_rate,omitempty"`
+	AddExcludedPaths    []string `json:"add_excluded_paths,omitempty"`
+	RemoveExcludedPaths []string `json:"remove_excluded_paths,omitempty"`
+}
+
+// Handler shows middleware state on GET and applies Update on PATCH,
+// all changes of single update take effect at once.
+type Handler struct {
+	m *httpdump.Middleware
+}
+
+// NewHandler creates a new admin handler for middleware.
+func NewHandler(m *httpdump.Middleware) *Handler {
+	return &Handler{m: m}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		if err := h.update(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PATCH")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", httpdump.MimeApplicationJSON)
+	_ = json.NewEncoder(w).Encode(h.State())
+}
+
+// State returns current middleware state.
+func (h *Handler) State() State {
+	s := h.m.Settings()
+	st := h.m.Stats()
+
+	paths := make([]string, 0, len(s.ExcludedPaths))
+	for _, re := range s.ExcludedPaths {
+		paths = append(paths, re.String())
+	}
+
+	return State{
+		Enabled:       s.Enabled,
+		BodyLimit:     s.BodyLimit,
+		ExcludedPaths: paths,
+		SampleRate:    s.SampleRate,
+		Queued:        st.Queued,
+		Dropped:       st.Dropped,
+		Filtered:      st.Filtered,
+	}
+}
+
+func (h *Handler) update(r *http.Request) error {
+	var u Update
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&u); err != nil {
+		return fmt.Errorf("admin: invalid update: %w", err)
+	}
+
+	return h.Apply(&u)
+}
+
+// Apply applies settings update.
+func (h *Handler) Apply(u *Update) error {
+	added := make([]*regexp.Regexp, 0, len(u.AddExcludedPaths))
+	for _, p := range u.AddExcludedPaths {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("admin: invalid excluded path: %w", err)
+		}
+		added = append(added, re)
+	}
+
+	return h.m.UpdateSettings(func(s *httpdump.Settings) {
+		if u.Enabled != nil {
+			s.Enabled = *u.Enabled
+		}
+
+		if u.BodyLimit != nil {
+			s.BodyLimit = *u.BodyLimit
+		}
+
+		if u.SampleRate != nil {
+			s.SampleRate = *u.SampleRate
+		}
+
+		s.ExcludedPaths = slices.DeleteFunc(s.ExcludedPaths, func(re *regexp.Regexp) bool {
+			return slices.Contains(u.RemoveExcludedPaths, re.String())
+		})
+		s.ExcludedPaths = append(s.ExcludedPaths, added...)
+	})
+}
@@ -0,0 +1,105 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/admin"
+)
+
+func TestHandler(t *testing.T) {
+	var (
+		dumped []string
+		bodies []string
+	)
+
+	m := httpdump.NewMiddleware(
+		func(rq *http.Request, body []byte) {
+			dumped = append(dumped, rq.URL.Path)
+			bodies = append(bodies, string(body))
+		},
+		nil,
+		httpdump.WithPathFilter(regexp.MustCompile("^/health")),
+	)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(p string) {
+		req := httptest.NewRequest(http.MethodPost, p, strings.NewReader("request body"))
+		req.Header.Set("Content-Type", httpdump.MimeTextPlain)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	ah := admin.NewHandler(m)
+
+	state := request(t, ah, http.MethodGet, "")
+	if !state.Enabled || state.BodyLimit != httpdump.DefaultBodySize || state.SampleRate != 1 ||
+		len(state.ExcludedPaths) != 1 || state.ExcludedPaths[0] != "^/health" {
+		t.Fatalf("Unexpected initial state %+v", state)
+	}
+
+	serve("/health")
+	serve("/api")
+
+	state = request(t, ah, http.MethodPatch, `{
+		"body_limit": 7,
+		"add_excluded_paths": ["^/api"],
+		"remove_excluded_paths": ["^/health"]
+	}`)
+
+	if state.BodyLimit != 7 || len(state.ExcludedPaths) != 1 || state.ExcludedPaths[0] != "^/api" {
+		t.Fatalf("Unexpected updated state %+v", state)
+	}
+
+	serve("/health")
+	serve("/api")
+
+	if strings.Join(dumped, ",") != "/api,/health" {
+		t.Errorf("Unexpected dumped requests %v", dumped)
+	}
+
+	if bodies[1] != "request" {
+		t.Errorf("Expected new body limit to be used, got %q", bodies[1])
+	}
+
+	request(t, ah, http.MethodPatch, `{"enabled": false}`)
+	if m.Enabled() {
+		t.Errorf("Expected middleware to be disabled")
+	}
+
+	for _, u := range []string{`{"body_limit": 0}`, `{"body_limit": 1099511627776}`, `{"sample_rate": 2}`, `{"add_excluded_paths": ["("]}`, `{"unknown": 1}`} {
+		rec := httptest.NewRecorder()
+		ah.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(u)))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected update %s to be rejected, got %d", u, rec.Code)
+		}
+	}
+
+	if s := m.Settings(); s.BodyLimit != 7 || s.SampleRate != 1 {
+		t.Errorf("Expected rejected updates not to be applied, got %+v", s)
+	}
+}
+
+func request(t *testing.T, h http.Handler, method, body string) admin.State {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, "/", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var s admin.State
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
@@ -0,0 +1,243 @@
+package httpdump
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// QueuePolicy defines what happens with a dump when async queue is full.
+type QueuePolicy int
+
+const (
+	// PolicyBlock blocks request handling until there is space in the queue,
+	// blocked dumps are dropped on shutdown.
+	PolicyBlock QueuePolicy = iota
+	// PolicyDropNewest drops the dump that is being queued.
+	PolicyDropNewest
+	// PolicyDropOldest drops the oldest queued dump to free space for the new one.
+	PolicyDropOldest
+)
+
+// Stats is a middleware statistics.
+type Stats struct {
+	// Queued is a number of dumps waiting in async queue.
+	Queued int
+	// Dropped is a number of dumps dropped because async queue was full
+	// or middleware was shut down.
+	Dropped uint64
+	// Filtered is a number of requests that were not dumped because of
+	// filters, excluded paths or sampling.
+	Filtered uint64
+}
+
+// WithAsyncDump creates a new option that calls dump functions asynchronously
+// in separate worker goroutines. Dumped data is copied out of pooled buffers
+// before it is queued. Use Middleware.Shutdown to drain the queue.
+//
+// When more than one worker is used, request and response dumps of the same
+// exchange may be processed in any order.
+func WithAsyncDump(queueSize, workers int, policy QueuePolicy) Option {
+	if queueSize <= 0 {
+		panic("httpdump: queue size must be greater than 0")
+	}
+
+	if workers <= 0 {
+		panic("httpdump: workers count must be greater than 0")
+	}
+
+	return func(m *Middleware) {
+		m.async = &asyncQueue{
+			lock:    make(chan struct{}, 1),
+			done:    make(chan struct{}),
+			queue:   make(chan dumpJob, queueSize),
+			workers: workers,
+			policy:  policy,
+		}
+	}
+}
+
+// dumpJob is a dump function with optional release function
+// that is called after dump is done or dropped.
+type dumpJob struct {
+	dump    func()
+	release func()
+}
+
+func (j dumpJob) run() {
+	defer j.done()
+	j.dump()
+}
+
+func (j dumpJob) done() {
+	if j.release != nil {
+		j.release()
+	}
+}
+
+type asyncQueue struct {
+	// lock guards closed and senders, it is a channel, so shutdown can stop waiting
+	// for it when context is done. It is never held while waiting for queue space.
+	lock    chan struct{}
+	closed  bool
+	senders int
+	// done is closed on shutdown to wake up blocked senders,
+	// queue is closed after that when there are no senders left
+	done    chan struct{}
+	queue   chan dumpJob
+	workers int
+	policy  QueuePolicy
+	wg      sync.WaitGroup
+	dropped atomic.Uint64
+}
+
+func (q *asyncQueue) start() {
+	q.wg.Add(q.workers)
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			defer q.wg.Done()
+
+			for job := range q.queue {
+				job.run()
+			}
+		}()
+	}
+}
+
+func (q *asyncQueue) push(job dumpJob) {
+	q.lock <- struct{}{}
+	if q.closed {
+		<-q.lock
+		q.drop(job)
+		return
+	}
+	q.senders++
+	<-q.lock
+
+	defer q.sent()
+
+	switch q.policy {
+	case PolicyDropNewest:
+		select {
+		case q.queue <- job:
+		default:
+			q.drop(job)
+		}
+	case PolicyDropOldest:
+		for {
+			select {
+			case q.queue <- job:
+				return
+			default:
+			}
+
+			select {
+			case old := <-q.queue:
+				q.drop(old)
+			default:
+			}
+		}
+	default:
+		select {
+		case q.queue <- job:
+		case <-q.done:
+			q.drop(job)
+		}
+	}
+}
+
+// sent closes queue if it is the last sender after shutdown.
+func (q *asyncQueue) sent() {
+	q.lock <- struct{}{}
+	q.senders--
+	if q.closed && q.senders == 0 {
+		close(q.queue)
+	}
+	<-q.lock
+}
+
+func (q *asyncQueue) drop(job dumpJob) {
+	q.dropped.Add(1)
+	job.done()
+}
+
+func (q *asyncQueue) shutdown(ctx context.Context) error {
+	select {
+	case q.lock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if !q.closed {
+		q.closed = true
+		close(q.done)
+		// otherwise queue is closed by the last sender
+		if q.senders == 0 {
+			close(q.queue)
+		}
+	}
+	<-q.lock
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dispatch calls dump job synchronously or queues it in async mode.
+func (m *Middleware) dispatch(job func()) {
+	m.dispatchRelease(job, nil)
+}
+
+// dispatchRelease is like dispatch but also calls release after dump job
+// is done or dropped.
+func (m *Middleware) dispatchRelease(job, release func()) {
+	j := dumpJob{dump: job, release: release}
+
+	if m.async == nil {
+		j.run()
+		return
+	}
+
+	m.async.push(j)
+}
+
+// Shutdown stops accepting new async dumps and waits until queued dumps are processed
+// or context is done. Dumps of requests handled after Shutdown are dropped.
+// It does nothing if middleware is not async.
+func (m *Middleware) Shutdown(ctx context.Context) error {
+	if m.async == nil {
+		return nil
+	}
+
+	return m.async.shutdown(ctx)
+}
+
+// Stats returns middleware statistics. Safe to call from multiple goroutines.
+func (m *Middleware) Stats() Stats {
+	s := Stats{
+		Filtered: m.filtered.Load(),
+	}
+
+	if m.async != nil {
+		s.Queued = len(m.async.queue)
+		s.Dropped = m.async.dropped.Load()
+	}
+
+	return s
+}
+
+// countFiltered counts request that is dumped neither with request nor with response.
+func (m *Middleware) countFiltered(reqDumped, respDumped bool) {
+	if !reqDumped && !respDumped && (m.dumpRequest != nil || m.needDumpResponse()) {
+		m.filtered.Add(1)
+	}
+}
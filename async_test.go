@@ -0,0 +1,233 @@
+package httpdump_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_AsyncDump(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		reqBodies []string
+		exchanges []*httpdump.Exchange
+	)
+
+	m := httpdump.NewMiddleware(
+		func(rq *http.Request, body []byte) {
+			mu.Lock()
+			reqBodies = append(reqBodies, string(body))
+			mu.Unlock()
+		},
+		nil,
+		httpdump.WithDumpExchange(func(ex *httpdump.Exchange) {
+			if ex.Request.Context().Err() != nil {
+				t.Errorf("Dumped request context must not be canceled")
+			}
+
+			mu.Lock()
+			exchanges = append(exchanges, ex)
+			mu.Unlock()
+		}),
+		httpdump.WithAsyncDump(10, 2, httpdump.PolicyBlock))
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("response " + r.URL.Path))
+	}))
+
+	for _, p := range []string{"/a", "/b", "/c"} {
+		req := httptest.NewRequest(http.MethodPost, p, strings.NewReader("request "+p))
+		req.Header.Set("Content-Type", "text/plain")
+
+		ctx, cancel := context.WithCancel(req.Context())
+		req = req.WithContext(ctx)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		cancel()
+
+		// changes after handler returns must not affect queued dumps
+		req.Header.Set("Content-Type", "changed")
+		rec.Header().Set("Content-Type", "changed")
+	}
+
+	err := m.Shutdown(context.Background())
+	noerr(t, err)
+
+	if len(reqBodies) != 3 || len(exchanges) != 3 {
+		t.Fatalf("Expected 3 dumps, got %d %d", len(reqBodies), len(exchanges))
+	}
+
+	for _, ex := range exchanges {
+		p := ex.Request.URL.Path
+
+		if string(ex.RequestBody) != "request "+p || string(ex.ResponseBody) != "response "+p {
+			t.Errorf("Unexpected exchange bodies %q %q", ex.RequestBody, ex.ResponseBody)
+		}
+
+		if ex.Request.Header.Get("Content-Type") != "text/plain" ||
+			ex.ResponseHeader.Get("Content-Type") != "text/plain" {
+			t.Errorf("Expected headers to be copied, got %v %v", ex.Request.Header, ex.ResponseHeader)
+		}
+	}
+
+	if s := m.Stats(); s.Dropped != 0 || s.Queued != 0 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+}
+
+func TestMiddleware_AsyncDropPolicy(t *testing.T) {
+	cases := []struct {
+		policy   httpdump.QueuePolicy
+		expected []string
+	}{
+		{
+			policy:   httpdump.PolicyDropNewest,
+			expected: []string{"/1", "/2"},
+		},
+		{
+			policy:   httpdump.PolicyDropOldest,
+			expected: []string{"/1", "/3"},
+		},
+	}
+
+	for _, c := range cases {
+		var (
+			mu      sync.Mutex
+			dumped  []string
+			started = make(chan struct{}, 3)
+			release = make(chan struct{})
+		)
+
+		m := httpdump.NewMiddleware(
+			nil,
+			nil,
+			httpdump.WithDumpExchange(func(ex *httpdump.Exchange) {
+				started <- struct{}{}
+				<-release
+
+				mu.Lock()
+				dumped = append(dumped, ex.Request.URL.Path)
+				mu.Unlock()
+			}),
+			httpdump.WithAsyncDump(1, 1, c.policy))
+
+		h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/1", http.NoBody))
+
+		// wait for worker to take the first dump, so the queue is empty
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("Dump is not started")
+		}
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/2", http.NoBody))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/3", http.NoBody))
+
+		if s := m.Stats(); s.Dropped != 1 || s.Queued != 1 {
+			t.Errorf("Unexpected stats %+v", s)
+		}
+
+		close(release)
+
+		err := m.Shutdown(context.Background())
+		noerr(t, err)
+
+		if strings.Join(dumped, ",") != strings.Join(c.expected, ",") {
+			t.Errorf("Expected dumps %v, got %v", c.expected, dumped)
+		}
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/4", http.NoBody))
+
+		if s := m.Stats(); s.Dropped != 2 {
+			t.Errorf("Expected dump after shutdown to be dropped, got %+v", s)
+		}
+	}
+}
+
+func TestMiddleware_AsyncShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	m := httpdump.NewMiddleware(
+		nil,
+		func(rp *http.Response, body []byte, duration time.Duration) {
+			<-release
+		},
+		httpdump.WithAsyncDump(1, 1, httpdump.PolicyBlock))
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := m.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected shutdown to time out, got %v", err)
+	}
+}
+
+func TestMiddleware_AsyncShutdownBlockedSender(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{}, 1)
+
+	m := httpdump.NewMiddleware(
+		nil,
+		func(rp *http.Response, body []byte, duration time.Duration) {
+			started <- struct{}{}
+			<-release
+		},
+		httpdump.WithAsyncDump(1, 1, httpdump.PolicyBlock))
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}
+
+	// the first dump blocks worker and the second one fills the queue
+	serve()
+	<-started
+	serve()
+
+	blocked := make(chan struct{})
+	go func() {
+		defer close(blocked)
+		serve()
+	}()
+
+	// let the third request block on full queue
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := m.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected shutdown to time out, got %v", err)
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Expected shutdown to respect context, took %v", d)
+	}
+
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("Expected blocked request to be released on shutdown")
+	}
+
+	if s := m.Stats(); s.Dropped != 1 {
+		t.Errorf("Expected blocked dump to be dropped, got %+v", s)
+	}
+}
@@ -0,0 +1,95 @@
+package httpdump
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+type stateKey struct{}
+
+// requestState is a per request middleware state,
+// it is shared by request and response filters.
+type requestState struct {
+	id    string
+	start time.Time
+	// unsampled is set by sampling filters if request is not sampled,
+	// kept is set by response filters that keep response of not sampled request
+	unsampled bool
+	kept      bool
+	// lazy is set if request is captured lazily,
+	// bodyRead is a number of body bytes read before request is dumped
+	lazy     bool
+	bodyRead int64
+	// activation is an id of activation that matched request
+	activation string
+	// informational is a list of informational responses sent before dumped response
+	informational []InformationalResponse
+	// panic is a panic recovered from handler
+	panic *PanicInfo
+	// body sizes and truncation flags are updated while request is handled
+	// and may be read by async dumps, see RequestBodySize
+	requestBytes      atomic.Int64
+	requestTruncated  atomic.Bool
+	responseTruncated atomic.Bool
+}
+
+func (st *requestState) setRequestBody(n int64, truncated bool) {
+	st.requestBytes.Store(n)
+	st.requestTruncated.Store(truncated)
+}
+
+var (
+	// requestIDPrefix makes request ids unique across processes
+	requestIDPrefix = newRequestIDPrefix()
+	requestCounter  atomic.Uint64
+)
+
+func newRequestIDPrefix() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newRequestState(start time.Time) *requestState {
+	return &requestState{
+		id:    requestIDPrefix + "-" + strconv.FormatUint(requestCounter.Add(1), 10),
+		start: start,
+	}
+}
+
+// RequestID returns id of request handled by middleware or transport,
+// dumps of the same request have the same id. It returns empty string
+// for requests that are not handled by middleware.
+func RequestID(r *http.Request) string {
+	st := stateFromRequest(r)
+	if st == nil {
+		return ""
+	}
+
+	return st.id
+}
+
+// RequestStart returns time when middleware or transport started to handle request.
+// It returns zero time for requests that are not handled by middleware.
+func RequestStart(r *http.Request) time.Time {
+	st := stateFromRequest(r)
+	if st == nil {
+		return time.Time{}
+	}
+
+	return st.start
+}
+
+func withRequestState(r *http.Request, st *requestState) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), stateKey{}, st))
+}
+
+func stateFromRequest(r *http.Request) *requestState {
+	st, _ := r.Context().Value(stateKey{}).(*requestState)
+	return st
+}
@@ -0,0 +1,192 @@
+package httpdump
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	stdio "io"
+	"net/http"
+	"strings"
+)
+
+const (
+	HeaderContentEncoding = "Content-Encoding"
+)
+
+// BodyEncoding describes dumped body prefix that was decoded
+// according to Content-Encoding header, see WithDecompression.
+type BodyEncoding struct {
+	// ContentEncoding is a list of applied encodings.
+	ContentEncoding string
+	// EncodedSize is a size of captured encoded prefix.
+	EncodedSize int
+	// DecodedSize is a size of decoded prefix passed to dump functions.
+	DecodedSize int
+	// Truncated reports whether decoded prefix is not a complete body,
+	// because captured encoded prefix is truncated or dumped body limit is reached.
+	Truncated bool
+	// Err is a decoding error, original encoded prefix is dumped in that case.
+	Err error
+}
+
+// Decoder creates a reader that decodes data encoded with content encoding.
+// If returned reader implements io.Closer, it is closed after decoding.
+type Decoder func(r stdio.Reader) (stdio.Reader, error)
+
+// WithDecompression creates a new option that decodes dumped request and response
+// body prefixes according to Content-Encoding header. Encodings gzip and deflate
+// are supported out of the box, decoders for other encodings are added with
+// WithDecoder. Bytes sent over the wire are not changed.
+//
+// Truncated encoded prefix is decoded as far as possible, decoded body is limited
+// by dumped body size (see WithLimitedBody). Encoded and decoded sizes are reported
+// in Exchange.RequestEncoding and Exchange.ResponseEncoding.
+func WithDecompression() Option {
+	return func(m *Middleware) {
+		m.decompress = true
+	}
+}
+
+// WithDecoder creates a new option that adds decoder for content encoding,
+// it replaces built-in decoder of the same encoding. Decoders are used only
+// if decompression is enabled, see WithDecompression.
+func WithDecoder(encoding string, d Decoder) Option {
+	encoding = strings.ToLower(encoding)
+
+	return func(m *Middleware) {
+		if m.decoders == nil {
+			m.decoders = make(map[string]Decoder)
+		}
+		m.decoders[encoding] = d
+	}
+}
+
+// decodedBody decodes body prefix according to Content-Encoding header,
+// it returns body as is if decompression is disabled or body is not encoded.
+func (m *Middleware) decodedBody(h http.Header, body []byte, limit int) ([]byte, *BodyEncoding) {
+	if !m.decompress || len(body) == 0 {
+		return body, nil
+	}
+
+	encodings := contentEncodings(h)
+	if len(encodings) == 0 {
+		return body, nil
+	}
+
+	enc := &BodyEncoding{
+		ContentEncoding: strings.Join(encodings, ", "),
+		EncodedSize:     len(body),
+		DecodedSize:     len(body),
+	}
+
+	decoded := body
+
+	// encodings are listed in the order they were applied
+	for i := len(encodings) - 1; i >= 0; i-- {
+		d, truncated, err := m.decode(encodings[i], decoded, limit)
+		if err != nil {
+			enc.Err = err
+			return body, enc
+		}
+
+		decoded = d
+		enc.Truncated = enc.Truncated || truncated
+	}
+
+	enc.DecodedSize = len(decoded)
+
+	return decoded, enc
+}
+
+func contentEncodings(h http.Header) []string {
+	var encodings []string
+
+	for _, v := range h.Values(HeaderContentEncoding) {
+		for _, e := range strings.Split(v, ",") {
+			e = strings.ToLower(strings.TrimSpace(e))
+			if e != "" && e != "identity" {
+				encodings = append(encodings, e)
+			}
+		}
+	}
+
+	return encodings
+}
+
+var errUnsupportedEncoding = errors.New("httpdump: unsupported content encoding")
+
+// decode decodes at most limit bytes of data. Unexpected end of encoded
+// data is not an error, decoded part is returned as truncated.
+func (m *Middleware) decode(encoding string, data []byte, limit int) ([]byte, bool, error) {
+	d, ok := m.decoders[encoding]
+	if !ok {
+		d, ok = builtinDecoder(encoding)
+	}
+	if !ok {
+		return nil, false, fmt.Errorf("%w %q", errUnsupportedEncoding, encoding)
+	}
+
+	r, err := d(bytes.NewReader(data))
+	if err != nil {
+		if isTruncated(err) {
+			return nil, true, nil
+		}
+		return nil, false, err
+	}
+
+	if c, ok := r.(stdio.Closer); ok {
+		defer c.Close()
+	}
+
+	// read one more byte to know whether decoded body is truncated by limit
+	decoded, err := stdio.ReadAll(stdio.LimitReader(r, int64(limit)+1))
+	if err != nil && !isTruncated(err) {
+		return nil, false, err
+	}
+
+	if len(decoded) > limit {
+		return decoded[:limit], true, nil
+	}
+
+	return decoded, err != nil, nil
+}
+
+// builtinDecoder returns decoder implemented with standard library.
+func builtinDecoder(encoding string) (Decoder, bool) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return func(r stdio.Reader) (stdio.Reader, error) {
+			return gzip.NewReader(r)
+		}, true
+	case "deflate":
+		return decodeDeflate, true
+	default:
+		return nil, false
+	}
+}
+
+// decodeDeflate decodes zlib stream, deflate is defined as zlib stream,
+// but some servers send raw deflate data.
+func decodeDeflate(r stdio.Reader) (stdio.Reader, error) {
+	// header is peeked, so raw data can be decoded from the beginning
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := zlib.NewReader(bytes.NewReader(header)); errors.Is(err, zlib.ErrHeader) {
+		return flate.NewReader(br), nil
+	}
+
+	return zlib.NewReader(br)
+}
+
+func isTruncated(err error) bool {
+	return errors.Is(err, stdio.ErrUnexpectedEOF) || errors.Is(err, stdio.EOF)
+}
@@ -0,0 +1,181 @@
+package httpdump_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_Decompression(t *testing.T) {
+	body := strings.Repeat(`{"some":"json"}`, 10)
+
+	encoders := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		"deflate": func(w io.Writer) io.WriteCloser {
+			return zlib.NewWriter(w)
+		},
+	}
+
+	for name, newEncoder := range encoders {
+		encoded := &bytes.Buffer{}
+		ew := newEncoder(encoded)
+		_, err := ew.Write([]byte(body))
+		noerr(t, err)
+		noerr(t, ew.Close())
+
+		var ex *httpdump.Exchange
+
+		m, dump := newMiddleware(true, true, []httpdump.Option{
+			httpdump.WithDecompression(),
+			httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+				ex = e
+			}),
+		})
+
+		h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := io.ReadAll(r.Body)
+			noerr(t, err)
+
+			w.Header().Set("Content-Type", httpdump.MimeApplicationJSON)
+			w.Header().Set("Content-Encoding", name)
+			w.Write(b)
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded.Bytes()))
+		req.Header.Set("Content-Type", httpdump.MimeApplicationJSON)
+		req.Header.Set("Content-Encoding", name)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if !bytes.Equal(rec.Body.Bytes(), encoded.Bytes()) {
+			t.Errorf("%s: expected bytes on the wire to be unchanged", name)
+		}
+
+		if string(dump.reqBody) != body || string(dump.respBody) != body {
+			t.Errorf("%s: unexpected decoded bodies %q %q", name, dump.reqBody, dump.respBody)
+		}
+
+		enc := ex.ResponseEncoding
+		if enc == nil || enc.EncodedSize != encoded.Len() || enc.DecodedSize != len(body) || enc.Truncated || enc.Err != nil {
+			t.Errorf("%s: unexpected response encoding %+v", name, enc)
+		}
+
+		if ex.RequestEncoding == nil || ex.RequestEncoding.ContentEncoding != name {
+			t.Errorf("%s: unexpected request encoding %+v", name, ex.RequestEncoding)
+		}
+	}
+}
+
+func TestMiddleware_DecompressionDecoder(t *testing.T) {
+	upper := func(r io.Reader) (io.Reader, error) {
+		b, err := io.ReadAll(r)
+		return strings.NewReader(strings.ToUpper(string(b))), err
+	}
+
+	var ex *httpdump.Exchange
+
+	m, dump := newMiddleware(true, true, []httpdump.Option{
+		httpdump.WithDecompression(),
+		httpdump.WithDecoder("X-Upper", upper),
+		httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+			ex = e
+		}),
+	})
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpdump.MimeTextPlain)
+		w.Header().Set("Content-Encoding", "br")
+		w.Write([]byte("response"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("request"))
+	req.Header.Set("Content-Type", httpdump.MimeTextPlain)
+	req.Header.Set("Content-Encoding", "x-upper")
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if string(dump.reqBody) != "REQUEST" {
+		t.Errorf("Expected request to be decoded by added decoder, got %q", dump.reqBody)
+	}
+
+	if string(dump.respBody) != "response" || ex.ResponseEncoding == nil || ex.ResponseEncoding.Err == nil {
+		t.Errorf("Expected unsupported encoding to be dumped as is with error, got %q %+v", dump.respBody, ex.ResponseEncoding)
+	}
+}
+
+func TestMiddleware_DecompressionTruncated(t *testing.T) {
+	body := strings.Repeat("0123456789abcdef", 1000)
+
+	encoded := &bytes.Buffer{}
+	gw, _ := gzip.NewWriterLevel(encoded, gzip.NoCompression)
+	gw.Write([]byte(body))
+	gw.Close()
+
+	var ex *httpdump.Exchange
+
+	m, dump := newMiddleware(false, true, []httpdump.Option{
+		httpdump.WithDecompression(),
+		httpdump.WithLimitedBody(100),
+		httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+			ex = e
+		}),
+	})
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpdump.MimeTextPlain)
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(encoded.Bytes())
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if len(dump.respBody) == 0 || !strings.HasPrefix(body, string(dump.respBody)) {
+		t.Errorf("Expected truncated stream to be decoded, got %q", dump.respBody)
+	}
+
+	enc := ex.ResponseEncoding
+	if enc == nil || enc.EncodedSize != 100 || !enc.Truncated || enc.Err != nil {
+		t.Errorf("Unexpected response encoding %+v", enc)
+	}
+}
+
+func TestMiddleware_DecompressionHeaderChangedAfterWrite(t *testing.T) {
+	body := `{"token":"secret"}`
+
+	encoded := &bytes.Buffer{}
+	gw := gzip.NewWriter(encoded)
+	_, err := gw.Write([]byte(body))
+	noerr(t, err)
+	noerr(t, gw.Close())
+
+	m, dump := newMiddleware(true, true, []httpdump.Option{
+		httpdump.WithDecompression(),
+		httpdump.WithRedaction(httpdump.RedactJSONPaths("token")),
+	})
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpdump.MimeApplicationJSON)
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(encoded.Bytes())
+
+		// headers are already sent, so changes do not affect response
+		w.Header().Del("Content-Encoding")
+		w.Header().Set("Content-Type", httpdump.MimeTextPlain)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if string(dump.respBody) != `{"token":"[REDACTED]"}` {
+		t.Errorf("Expected response to be decoded and redacted by sent headers, got %q", dump.respBody)
+	}
+}
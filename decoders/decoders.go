@@ -0,0 +1,36 @@
+// Package decoders provides brotli and zstd decoders for httpdump middleware,
+// see httpdump.WithDecompression. Decoders live in separate module,
+// so httpdump itself does not depend on third-party compression libraries.
+package decoders
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/hummerd/httpdump"
+)
+
+// WithAll creates a new option that adds all decoders of this package to middleware.
+func WithAll() httpdump.Option {
+	return func(m *httpdump.Middleware) {
+		httpdump.WithDecoder("br", Brotli)(m)
+		httpdump.WithDecoder("zstd", Zstd)(m)
+	}
+}
+
+// Brotli decodes br content encoding.
+func Brotli(r io.Reader) (io.Reader, error) {
+	return brotli.NewReader(r), nil
+}
+
+// Zstd decodes zstd content encoding.
+func Zstd(r io.Reader) (io.Reader, error) {
+	zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+	if err != nil {
+		return nil, err
+	}
+
+	return zr.IOReadCloser(), nil
+}
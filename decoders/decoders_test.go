@@ -0,0 +1,89 @@
+package decoders_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/decoders"
+)
+
+func TestWithAll(t *testing.T) {
+	body := strings.Repeat(`{"some":"json"}`, 10)
+
+	encoders := map[string]func(w io.Writer) io.WriteCloser{
+		"br": func(w io.Writer) io.WriteCloser {
+			return brotli.NewWriter(w)
+		},
+		"zstd": func(w io.Writer) io.WriteCloser {
+			zw, _ := zstd.NewWriter(w)
+			return zw
+		},
+	}
+
+	for name, newEncoder := range encoders {
+		encoded := &bytes.Buffer{}
+		ew := newEncoder(encoded)
+		if _, err := ew.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		if err := ew.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var (
+			reqBody, respBody []byte
+			ex                *httpdump.Exchange
+		)
+
+		m := httpdump.NewMiddleware(
+			func(r *http.Request, body []byte) {
+				reqBody = body
+			},
+			func(r *http.Response, body []byte, _ time.Duration) {
+				respBody = body
+			},
+			httpdump.WithDecompression(),
+			decoders.WithAll(),
+			httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+				ex = e
+			}),
+		)
+
+		h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+
+			w.Header().Set("Content-Type", httpdump.MimeApplicationJSON)
+			w.Header().Set("Content-Encoding", name)
+			w.Write(b)
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encoded.Bytes()))
+		req.Header.Set("Content-Type", httpdump.MimeApplicationJSON)
+		req.Header.Set("Content-Encoding", name)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if !bytes.Equal(rec.Body.Bytes(), encoded.Bytes()) {
+			t.Errorf("%s: expected bytes on the wire to be unchanged", name)
+		}
+
+		if string(reqBody) != body || string(respBody) != body {
+			t.Errorf("%s: unexpected decoded bodies %q %q", name, reqBody, respBody)
+		}
+
+		enc := ex.ResponseEncoding
+		if enc == nil || enc.EncodedSize != encoded.Len() || enc.DecodedSize != len(body) || enc.Truncated || enc.Err != nil {
+			t.Errorf("%s: unexpected response encoding %+v", name, enc)
+		}
+	}
+}
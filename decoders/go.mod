@@ -0,0 +1,9 @@
+module github.com/hummerd/httpdump/decoders
+
+go 1.22.0
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/hummerd/httpdump v0.0.0-20261016123548-118952868266
+	github.com/klauspost/compress v1.18.0
+)
@@ -0,0 +1,127 @@
+package httpdump
+
+import (
+	stdio "io"
+	"net/http"
+	"time"
+
+	"github.com/hummerd/httpdump/io"
+)
+
+// Exchange is a request and response pair captured by middleware.
+// Body slices are valid only during the dump function call,
+// copy them if they are needed later. Request and headers are copies
+// shared by all dumps of the same request, they must not be modified.
+type Exchange struct {
+	Request     *http.Request
+	RequestBody []byte
+	// Pattern is a http.ServeMux pattern that matched request, it is known only
+	// when middleware wraps the mux and program is built with Go 1.23 or later.
+	Pattern string
+
+	StatusCode     int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+
+	Start    time.Time
+	Duration time.Duration
+
+	// RequestBytes is a request body size: declared content length if it is known,
+	// otherwise number of body bytes read by middleware and handler, -1 if it is unknown.
+	RequestBytes int64
+	// RequestTruncated reports whether RequestBody is only a prefix of request body,
+	// body of unknown size is truncated if its prefix reaches body limit.
+	RequestTruncated bool
+	// ResponseBytes is a number of response body bytes written by handler,
+	// -1 if it is unknown.
+	ResponseBytes int64
+	// ResponseTruncated reports whether ResponseBody is only a prefix of response body.
+	ResponseTruncated bool
+
+	// FullRequestBody is a complete request body read by handler and
+	// FullResponseBody is a complete response body, both are nil
+	// unless WithFullBody is used and body is dumped.
+	FullRequestBody  Body
+	FullResponseBody Body
+
+	// RequestEncoding and ResponseEncoding describe decoded bodies,
+	// nil if body is not decoded, see WithDecompression.
+	RequestEncoding  *BodyEncoding
+	ResponseEncoding *BodyEncoding
+
+	// RequestTrailer is a request trailer, it is complete only if
+	// handler read the whole request body.
+	RequestTrailer http.Header
+	// ResponseTrailer is a trailer set by handler, see http.TrailerPrefix.
+	ResponseTrailer http.Header
+	// Informational is a list of informational (1xx) responses sent before final response.
+	Informational []InformationalResponse
+
+	// Panic is a panic recovered from handler, see WithPanicRecovery.
+	Panic *PanicInfo
+}
+
+// RequestBodySize returns request body size and reports whether dumped request body
+// is only a prefix of it, see Exchange.RequestBytes and Exchange.RequestTruncated.
+// For request dumps size of body with unknown length is a size of prefix read
+// before handler is called, it is updated when handler returns.
+// It returns -1 and false for requests that are not handled by middleware.
+func RequestBodySize(r *http.Request) (n int64, truncated bool) {
+	st := stateFromRequest(r)
+	if st == nil {
+		return -1, false
+	}
+
+	return st.requestBytes.Load(), st.requestTruncated.Load()
+}
+
+// ResponseBodyTruncated reports whether dumped response body is only a prefix
+// of response body, its size is http.Response.ContentLength.
+func ResponseBodyTruncated(resp *http.Response) bool {
+	if resp.Request == nil {
+		return false
+	}
+
+	st := stateFromRequest(resp.Request)
+	return st != nil && st.responseTruncated.Load()
+}
+
+// requestBodySize returns request body size and whether captured prefix is truncated,
+// read is a number of body bytes read by handler.
+func requestBodySize(r *http.Request, cr *io.PrefixReader, read int64, limit int) (int64, bool) {
+	var prefix int
+	if cr != nil {
+		prefix = len(cr.Prefix())
+	}
+
+	n := r.ContentLength
+	if n < 0 {
+		n = max(read, int64(prefix))
+	}
+
+	return n, cr != nil && (cr.Truncated() || prefixTruncated(r.ContentLength, prefix, limit))
+}
+
+// DumpExchangeFunc is called once per request after handler returns.
+type DumpExchangeFunc func(ex *Exchange)
+
+// WithDumpExchange creates a new option that sets exchange dump function.
+// Exchange is dumped if it is not excluded by request or response filters,
+// bodies are dumped according to filters result. Requests not sampled by
+// sampling filters are dumped if response is kept, see KeepErrorsAndSlow.
+func WithDumpExchange(dumpExchange DumpExchangeFunc) Option {
+	return func(m *Middleware) {
+		m.dumpExchange = dumpExchange
+	}
+}
+
+type countingReader struct {
+	stdio.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,226 @@
+package httpdump_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_DumpExchange(t *testing.T) {
+	reqBody := `{ "some": "json" }`
+	respBody := "Welcome!"
+
+	var exchanges []httpdump.Exchange
+
+	m := httpdump.NewMiddleware(
+		nil,
+		nil,
+		httpdump.WithDumpExchange(func(ex *httpdump.Exchange) {
+			c := *ex
+			c.RequestBody = bytes.Clone(ex.RequestBody)
+			c.ResponseBody = bytes.Clone(ex.ResponseBody)
+			exchanges = append(exchanges, c)
+		}),
+		httpdump.WithPathFilter(regexp.MustCompile("skipped")),
+	)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		noerr(t, err)
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(respBody))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/somepath", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", httpdump.MimeApplicationJSON)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPost, "/skipped", strings.NewReader(reqBody))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(exchanges) != 1 {
+		t.Fatalf("Expected 1 exchange, got %d", len(exchanges))
+	}
+
+	ex := exchanges[0]
+
+	if ex.Request.URL.Path != "/somepath" || string(ex.RequestBody) != reqBody {
+		t.Errorf("Unexpected request %v %q", ex.Request.URL, ex.RequestBody)
+	}
+
+	if ex.StatusCode != http.StatusCreated || string(ex.ResponseBody) != respBody {
+		t.Errorf("Unexpected response %v %q", ex.StatusCode, ex.ResponseBody)
+	}
+
+	if ex.ResponseHeader.Get("Content-Type") != "text/plain" {
+		t.Errorf("Unexpected response headers %v", ex.ResponseHeader)
+	}
+
+	if ex.RequestBytes != int64(len(reqBody)) || ex.ResponseBytes != int64(len(respBody)) {
+		t.Errorf("Unexpected byte counts %d %d", ex.RequestBytes, ex.ResponseBytes)
+	}
+
+	if ex.Start.IsZero() || ex.Duration == 0 {
+		t.Errorf("Unexpected timing %v %v", ex.Start, ex.Duration)
+	}
+}
+
+func TestTransport_DumpExchange(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("pong"))
+	}))
+	defer s.Close()
+
+	var ex *httpdump.Exchange
+
+	tr := httpdump.NewTransport(
+		s.Client().Transport,
+		nil,
+		nil,
+		httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+			ex = e
+		}))
+
+	c := &http.Client{Transport: tr}
+
+	resp, err := c.Post(s.URL, "text/plain", strings.NewReader("ping"))
+	noerr(t, err)
+
+	if ex != nil {
+		t.Fatal("Expected exchange to be dumped after response body is read")
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if ex == nil {
+		t.Fatal("Expected exchange to be dumped")
+	}
+
+	if string(ex.RequestBody) != "ping" || string(ex.ResponseBody) != "pong" {
+		t.Errorf("Unexpected bodies %q %q", ex.RequestBody, ex.ResponseBody)
+	}
+
+	if ex.RequestBytes != 4 || ex.ResponseBytes != 4 {
+		t.Errorf("Unexpected byte counts %d %d", ex.RequestBytes, ex.ResponseBytes)
+	}
+}
+
+func TestMiddleware_DumpExchangeTruncated(t *testing.T) {
+	var ex *httpdump.Exchange
+
+	m, dump := newMiddleware(false, true, []httpdump.Option{
+		httpdump.WithLimitedBody(4),
+		httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+			ex = e
+		}),
+	})
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+
+		if r.URL.Path == "/image" {
+			w.Header().Set("Content-Type", "image/png")
+		} else {
+			w.Header().Set("Content-Type", httpdump.MimeTextPlain)
+		}
+		w.Write([]byte("response body"))
+	}))
+
+	for _, p := range []string{"/text", "/image"} {
+		req := httptest.NewRequest(http.MethodPost, p, strings.NewReader("request body"))
+		req.Header.Set("Content-Type", httpdump.MimeTextPlain)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if ex.RequestBytes != 12 || !ex.RequestTruncated || string(ex.RequestBody) != "requ" {
+			t.Errorf("%s: unexpected request body %d %v %q", p, ex.RequestBytes, ex.RequestTruncated, ex.RequestBody)
+		}
+
+		if ex.ResponseBytes != 13 || dump.resp.ContentLength != 13 {
+			t.Errorf("%s: unexpected response size %d %d", p, ex.ResponseBytes, dump.resp.ContentLength)
+		}
+	}
+
+	if ex.ResponseTruncated || len(ex.ResponseBody) != 0 {
+		t.Errorf("Expected not dumped response body not to be truncated")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/text", strings.NewReader("requ"))
+	req.Header.Set("Content-Type", httpdump.MimeTextPlain)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if ex.RequestTruncated || !ex.ResponseTruncated {
+		t.Errorf("Unexpected truncated flags %v %v", ex.RequestTruncated, ex.ResponseTruncated)
+	}
+}
+
+func TestMiddleware_DumpExchangeUnreadBody(t *testing.T) {
+	type size struct {
+		n         int64
+		truncated bool
+	}
+
+	var (
+		ex                   *httpdump.Exchange
+		reqSize, respReqSize size
+		respTruncated        bool
+	)
+
+	m := httpdump.NewMiddleware(
+		func(rq *http.Request, body []byte) {
+			reqSize.n, reqSize.truncated = httpdump.RequestBodySize(rq)
+		},
+		func(rp *http.Response, body []byte, _ time.Duration) {
+			respReqSize.n, respReqSize.truncated = httpdump.RequestBodySize(rp.Request)
+			respTruncated = httpdump.ResponseBodyTruncated(rp)
+		},
+		httpdump.WithLimitedBody(4),
+		httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+			ex = e
+		}),
+	)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpdump.MimeTextPlain)
+		w.Write([]byte("response body"))
+	}))
+
+	body := strings.Repeat("a", 10000)
+
+	for _, contentLength := range []int64{10000, -1} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", httpdump.MimeTextPlain)
+		req.ContentLength = contentLength
+
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		expected := size{n: contentLength, truncated: true}
+		if contentLength < 0 {
+			// only prefix is read from body of unknown size
+			expected.n = 4
+		}
+
+		if ex.RequestBytes != expected.n || !ex.RequestTruncated || string(ex.RequestBody) != "aaaa" {
+			t.Errorf("Unexpected exchange request body %d %v %q", ex.RequestBytes, ex.RequestTruncated, ex.RequestBody)
+		}
+
+		if reqSize != expected || respReqSize != expected {
+			t.Errorf("Expected request body size %+v, got %+v and %+v", expected, reqSize, respReqSize)
+		}
+
+		if !respTruncated || !ex.ResponseTruncated {
+			t.Errorf("Expected response body to be truncated")
+		}
+	}
+}
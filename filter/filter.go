@@ -0,0 +1,277 @@
+// Package filter provides composable predicates for middleware
+// request and response filters.
+//
+// Predicates are combined with And, Or and Not and converted to middleware
+// filters with Request, RequestBody, Response and ResponseBody.
+// Predicates can also be parsed from text expressions, see Parse.
+package filter
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+// Input is a filtered exchange. Header and Status are response headers and status,
+// they are empty for request filters.
+type Input struct {
+	Request *http.Request
+	Header  http.Header
+	Status  int
+}
+
+// Filter is a predicate that reports whether exchange matches it.
+type Filter func(in *Input) bool
+
+// Request creates a new request filter that dumps only requests matching f.
+func Request(f Filter) httpdump.RequestFilterFunc {
+	return func(r *http.Request) (bool, bool) {
+		ok := f(&Input{Request: r})
+		return ok, ok
+	}
+}
+
+// RequestBody creates a new request filter that dumps request body
+// only for requests matching f, other requests are dumped without body.
+func RequestBody(f Filter) httpdump.RequestFilterFunc {
+	return func(r *http.Request) (bool, bool) {
+		return true, f(&Input{Request: r})
+	}
+}
+
+// Response creates a new response filter that dumps only responses matching f.
+func Response(f Filter) httpdump.ResponseFilterFunc {
+	return func(r *http.Request, headers http.Header, status int) (bool, bool) {
+		ok := f(&Input{Request: r, Header: headers, Status: status})
+		return ok, ok
+	}
+}
+
+// ResponseBody creates a new response filter that dumps response body
+// only for responses matching f, other responses are dumped without body.
+func ResponseBody(f Filter) httpdump.ResponseFilterFunc {
+	return func(r *http.Request, headers http.Header, status int) (bool, bool) {
+		return true, f(&Input{Request: r, Header: headers, Status: status})
+	}
+}
+
+// And creates a new filter that matches if all filters match,
+// it matches everything if there are no filters.
+func And(filters ...Filter) Filter {
+	return func(in *Input) bool {
+		for _, f := range filters {
+			if !f(in) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// Or creates a new filter that matches if any of filters matches,
+// it matches nothing if there are no filters.
+func Or(filters ...Filter) Filter {
+	return func(in *Input) bool {
+		for _, f := range filters {
+			if f(in) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Not creates a new filter that matches if f does not match.
+func Not(f Filter) Filter {
+	return func(in *Input) bool {
+		return !f(in)
+	}
+}
+
+// Method creates a new filter that matches request method, case insensitive.
+func Method(methods ...string) Filter {
+	return func(in *Input) bool {
+		return containsFold(methods, in.Request.Method)
+	}
+}
+
+// Host creates a new filter that matches request host without port, case insensitive.
+func Host(hosts ...string) Filter {
+	return func(in *Input) bool {
+		return containsFold(hosts, requestHost(in.Request))
+	}
+}
+
+// PathPrefix creates a new filter that matches request path by prefix.
+func PathPrefix(prefix string) Filter {
+	return func(in *Input) bool {
+		return strings.HasPrefix(in.Request.URL.Path, prefix)
+	}
+}
+
+// PathGlob creates a new filter that matches request path by shell pattern,
+// see path.Match for pattern syntax. It panics if pattern is malformed.
+func PathGlob(pattern string) Filter {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic("filter: invalid path pattern " + strconv.Quote(pattern))
+	}
+
+	return func(in *Input) bool {
+		ok, _ := path.Match(pattern, in.Request.URL.Path)
+		return ok
+	}
+}
+
+// PathRegexp creates a new filter that matches request path by regexp.
+func PathRegexp(re *regexp.Regexp) Filter {
+	return func(in *Input) bool {
+		return re.MatchString(in.Request.URL.Path)
+	}
+}
+
+// HasHeader creates a new filter that matches requests with specified header.
+func HasHeader(name string) Filter {
+	return func(in *Input) bool {
+		return len(in.Request.Header.Values(name)) > 0
+	}
+}
+
+// HeaderValue creates a new filter that matches requests
+// having specified header with any of values.
+func HeaderValue(name string, values ...string) Filter {
+	return func(in *Input) bool {
+		return containsAny(in.Request.Header.Values(name), values)
+	}
+}
+
+// HasResponseHeader creates a new filter that matches responses with specified header.
+func HasResponseHeader(name string) Filter {
+	return func(in *Input) bool {
+		return len(in.Header.Values(name)) > 0
+	}
+}
+
+// ResponseHeaderValue creates a new filter that matches responses
+// having specified header with any of values.
+func ResponseHeaderValue(name string, values ...string) Filter {
+	return func(in *Input) bool {
+		return containsAny(in.Header.Values(name), values)
+	}
+}
+
+// HasQuery creates a new filter that matches requests with specified query parameter.
+func HasQuery(name string) Filter {
+	return func(in *Input) bool {
+		return in.Request.URL.Query().Has(name)
+	}
+}
+
+// QueryValue creates a new filter that matches requests
+// having specified query parameter with any of values.
+func QueryValue(name string, values ...string) Filter {
+	return func(in *Input) bool {
+		return containsAny(in.Request.URL.Query()[name], values)
+	}
+}
+
+// StatusRange creates a new filter that matches responses
+// with status between min and max inclusive. Negative max means no upper bound.
+func StatusRange(min, max int) Filter {
+	return func(in *Input) bool {
+		return inRange(int64(in.Status), int64(min), int64(max))
+	}
+}
+
+// RequestContentLength creates a new filter that matches requests with known
+// content length between min and max inclusive. Negative max means no upper bound.
+func RequestContentLength(min, max int64) Filter {
+	return func(in *Input) bool {
+		return contentLengthInRange(in.Request.ContentLength, min, max)
+	}
+}
+
+// ResponseContentLength creates a new filter that matches responses with
+// Content-Length header between min and max inclusive. Negative max means no upper bound.
+func ResponseContentLength(min, max int64) Filter {
+	return func(in *Input) bool {
+		return contentLengthInRange(responseContentLength(in.Header), min, max)
+	}
+}
+
+// Latency creates a new filter that matches exchanges handled for at least
+// threshold, latency is measured from request start (see httpdump.RequestStart)
+// to filter evaluation. It never matches requests not handled by middleware.
+//
+// Latency is useful for response filters, which are evaluated when response
+// headers are written.
+func Latency(threshold time.Duration) Filter {
+	return func(in *Input) bool {
+		start := httpdump.RequestStart(in.Request)
+		return !start.IsZero() && time.Since(start) >= threshold
+	}
+}
+
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	// strip port, but keep IPv6 address without brackets
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+
+	return strings.Trim(host, "[]")
+}
+
+func responseContentLength(h http.Header) int64 {
+	n, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return n
+}
+
+func contentLengthInRange(n, min, max int64) bool {
+	// unknown content length is not in any range
+	return n >= 0 && inRange(n, min, max)
+}
+
+func inRange(n, min, max int64) bool {
+	if max < 0 {
+		return n >= min
+	}
+
+	return n >= min && n <= max
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAny(list, values []string) bool {
+	for _, v := range list {
+		for _, w := range values {
+			if v == w {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package filter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/filter"
+)
+
+func TestPredicates(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "http://Example.com:8080/api/users/1?debug=1&tag=a&tag=b", strings.NewReader("body"))
+	req.Header.Set("X-Request-Id", "42")
+
+	in := &filter.Input{
+		Request: req,
+		Header:  http.Header{"Content-Length": {"100"}, "X-Cache": {"hit"}},
+		Status:  http.StatusNotFound,
+	}
+
+	tests := []struct {
+		name     string
+		f        filter.Filter
+		expected bool
+	}{
+		{"method", filter.Method("get", "post"), true},
+		{"method mismatch", filter.Method(http.MethodGet), false},
+		{"host", filter.Host("example.com"), true},
+		{"host mismatch", filter.Host("example.org"), false},
+		{"path prefix", filter.PathPrefix("/api/"), true},
+		{"path glob", filter.PathGlob("/api/*/[0-9]"), true},
+		{"path glob mismatch", filter.PathGlob("/api/*"), false},
+		{"path regexp", filter.PathRegexp(regexp.MustCompile(`/\d+$`)), true},
+		{"header", filter.HasHeader("x-request-id"), true},
+		{"header missing", filter.HasHeader("X-Debug"), false},
+		{"header value", filter.HeaderValue("X-Request-Id", "1", "42"), true},
+		{"response header", filter.HasResponseHeader("X-Cache"), true},
+		{"response header value", filter.ResponseHeaderValue("X-Cache", "miss"), false},
+		{"query", filter.HasQuery("debug"), true},
+		{"query value", filter.QueryValue("tag", "b"), true},
+		{"query value mismatch", filter.QueryValue("debug", "0"), false},
+		{"status range", filter.StatusRange(400, 499), true},
+		{"status unbounded", filter.StatusRange(500, -1), false},
+		{"request length", filter.RequestContentLength(1, 4), true},
+		{"request length mismatch", filter.RequestContentLength(5, -1), false},
+		{"response length", filter.ResponseContentLength(100, 100), true},
+		{"latency not handled", filter.Latency(0), false},
+		{"and", filter.And(filter.Method(http.MethodPost), filter.HasQuery("debug")), true},
+		{"and mismatch", filter.And(filter.Method(http.MethodPost), filter.HasQuery("trace")), false},
+		{"or", filter.Or(filter.HasQuery("trace"), filter.StatusRange(404, 404)), true},
+		{"or empty", filter.Or(), false},
+		{"not", filter.Not(filter.PathPrefix("/health")), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := tt.f(in); actual != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPathGlob_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic for malformed pattern")
+		}
+	}()
+
+	filter.PathGlob("/api/[")
+}
+
+func TestMiddleware_Filters(t *testing.T) {
+	var dumped []string
+
+	m := httpdump.NewMiddleware(
+		func(rq *http.Request, body []byte) {
+			dumped = append(dumped, "request "+rq.URL.Path+" "+string(body))
+		},
+		func(rp *http.Response, body []byte, _ time.Duration) {
+			dumped = append(dumped, "response "+rp.Request.URL.Path)
+		},
+		httpdump.WithRequestFilters(
+			filter.Request(filter.Not(filter.PathPrefix("/health"))),
+			filter.RequestBody(filter.Method(http.MethodPost)),
+		),
+		httpdump.WithResponseFilters(
+			filter.Response(filter.Or(filter.StatusRange(500, -1), filter.Latency(10*time.Millisecond))),
+		),
+	)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(20 * time.Millisecond)
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	for _, r := range []struct {
+		method, target string
+	}{
+		{http.MethodPost, "/fail"},
+		{http.MethodGet, "/slow"},
+		{http.MethodGet, "/fast"},
+		{http.MethodGet, "/health"},
+	} {
+		req := httptest.NewRequest(r.method, r.target, strings.NewReader("body"))
+		req.Header.Set(httpdump.HeaderContentType, httpdump.MimeTextPlain)
+
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	expected := []string{
+		"request /fail body",
+		"response /fail",
+		"request /slow ",
+		"response /slow",
+		"request /fast ",
+	}
+
+	if strings.Join(dumped, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected dumps:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(dumped, "\n"))
+	}
+}
@@ -0,0 +1,438 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hummerd/httpdump"
+)
+
+// SyntaxError is an error of filter expression parsing.
+type SyntaxError struct {
+	// Offset is a byte offset in expression where error occurred.
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Parse parses filter expression, so filters can be loaded from configuration.
+//
+// Expression is a comparisons combined with && (and), || (or), ! (not)
+// and parentheses, && has higher precedence than ||:
+//
+//	status >= 500 && path ~ "^/api" || latency > 2s
+//
+// Comparison is a field, operator and value. Supported fields are:
+//
+//	method, host, path            strings, method and host are compared case insensitive
+//	header.<name>                 request header values
+//	response_header.<name>        response header values
+//	query.<name>                  query parameter values
+//	status                        response status
+//	request_length                request content length, unknown length never matches
+//	response_length               response Content-Length, unknown length never matches
+//	latency                       time since request start, see Latency
+//
+// String fields support == and != with quoted string value, ~ and !~ with
+// quoted regexp. Header and query fields match if any of values matches,
+// field without comparison checks header or query parameter presence:
+//
+//	header.X-Debug && query.user == "42"
+//
+// Numeric fields support ==, !=, <, <=, > and >= with integer value,
+// latency value is a duration, such as 500ms or 1.5s.
+func Parse(expr string) (Filter, error) {
+	p := &parser{lex: lexer{src: expr}}
+	p.next()
+
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+
+	return f, nil
+}
+
+// MustParse is like Parse but panics if expression cannot be parsed.
+func MustParse(expr string) Filter {
+	f, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+
+	return f
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokInvalid
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+
+	return strconv.Quote(t.text)
+}
+
+// operators are ordered so that longer operators are matched first
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "<", ">", "~", "!", "(", ")"}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() token {
+	for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+
+	start := l.pos
+	if start == len(l.src) {
+		return token{kind: tokEOF, pos: start}
+	}
+
+	rest := l.src[start:]
+	c := rest[0]
+
+	switch {
+	case c == '"' || c == '`':
+		s, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			l.pos = len(l.src)
+			return token{kind: tokInvalid, text: rest, pos: start}
+		}
+
+		l.pos += len(s)
+		return token{kind: tokString, text: s, pos: start}
+	case c >= '0' && c <= '9':
+		// numbers may have duration units
+		l.pos += len(rest) - len(strings.TrimLeftFunc(rest, isNumberRune))
+		return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}
+	case isIdentStart(c):
+		l.pos += len(rest) - len(strings.TrimLeftFunc(rest, isIdentRune))
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}
+		}
+	}
+
+	l.pos++
+	return token{kind: tokInvalid, text: rest[:1], pos: start}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentRune(r rune) bool {
+	return r < unicode.MaxASCII && (isIdentStart(byte(r)) || (r >= '0' && r <= '9') || r == '.' || r == '-')
+}
+
+func isNumberRune(r rune) bool {
+	return (r >= '0' && r <= '9') || r == '.' || unicode.IsLetter(r)
+}
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) next() {
+	p.tok = p.lex.next()
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return errorAt(p.tok, format, args...)
+}
+
+func errorAt(t token, format string, args ...any) error {
+	return &SyntaxError{Offset: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+func (p *parser) parseOr() (Filter, error) {
+	f, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []Filter{f}
+	for p.isOp("||") {
+		p.next()
+
+		f, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, f)
+	}
+
+	if len(filters) == 1 {
+		return f, nil
+	}
+
+	return Or(filters...), nil
+}
+
+func (p *parser) parseAnd() (Filter, error) {
+	f, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []Filter{f}
+	for p.isOp("&&") {
+		p.next()
+
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, f)
+	}
+
+	if len(filters) == 1 {
+		return f, nil
+	}
+
+	return And(filters...), nil
+}
+
+func (p *parser) parseUnary() (Filter, error) {
+	switch {
+	case p.isOp("!"):
+		p.next()
+
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return Not(f), nil
+	case p.isOp("("):
+		p.next()
+
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.isOp(")") {
+			return nil, p.errorf("expected \")\", got %s", p.tok)
+		}
+
+		p.next()
+		return f, nil
+	case p.tok.kind == tokIdent:
+		return p.parseComparison()
+	}
+
+	return nil, p.errorf("expected field, got %s", p.tok)
+}
+
+func (p *parser) parseComparison() (Filter, error) {
+	field := p.tok
+	p.next()
+
+	strField, numField := lookupField(field.text)
+	if strField == nil && numField == nil {
+		return nil, errorAt(field, "unknown field %s", field)
+	}
+
+	op := p.tok
+	if op.kind != tokOp || !isComparison(op.text) {
+		if strField != nil && strings.Contains(field.text, ".") {
+			// presence check of header or query parameter
+			return func(in *Input) bool {
+				return len(strField(in)) > 0
+			}, nil
+		}
+
+		return nil, p.errorf("expected comparison operator after %s, got %s", field, op)
+	}
+
+	p.next()
+
+	value := p.tok
+	p.next()
+
+	if strField != nil {
+		return stringComparison(field, op, value, strField)
+	}
+
+	return numberComparison(field, op, value, numField)
+}
+
+func stringComparison(field, op, value token, f stringField) (Filter, error) {
+	if value.kind != tokString {
+		return nil, errorAt(value, "expected quoted string, got %s", value)
+	}
+
+	s, _ := strconv.Unquote(value.text)
+
+	var match func(string) bool
+
+	switch op.text {
+	case "==", "!=":
+		// method and host are case insensitive, see Method and Host
+		if field.text == "method" || field.text == "host" {
+			match = func(v string) bool { return strings.EqualFold(v, s) }
+		} else {
+			match = func(v string) bool { return v == s }
+		}
+	case "~", "!~":
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, errorAt(value, "invalid regexp: %v", err)
+		}
+
+		match = re.MatchString
+	default:
+		return nil, errorAt(op, "operator %s is not supported for %s", op, field)
+	}
+
+	matched := func(in *Input) bool {
+		for _, v := range f(in) {
+			if match(v) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if op.text == "!=" || op.text == "!~" {
+		return Not(matched), nil
+	}
+
+	return matched, nil
+}
+
+func numberComparison(field, op, value token, f numberField) (Filter, error) {
+	cmp := comparisons[op.text]
+	if cmp == nil {
+		return nil, errorAt(op, "operator %s is not supported for %s", op, field)
+	}
+
+	if value.kind != tokNumber {
+		return nil, errorAt(value, "expected number, got %s", value)
+	}
+
+	var (
+		n   int64
+		err error
+	)
+
+	if field.text == "latency" {
+		var d time.Duration
+		d, err = time.ParseDuration(value.text)
+		n = int64(d)
+	} else {
+		n, err = strconv.ParseInt(value.text, 10, 64)
+	}
+
+	if err != nil {
+		return nil, errorAt(value, "invalid %s value %s", field.text, value)
+	}
+
+	return func(in *Input) bool {
+		v, ok := f(in)
+		return ok && cmp(v, n)
+	}, nil
+}
+
+var comparisons = map[string]func(a, b int64) bool{
+	"==": func(a, b int64) bool { return a == b },
+	"!=": func(a, b int64) bool { return a != b },
+	"<":  func(a, b int64) bool { return a < b },
+	"<=": func(a, b int64) bool { return a <= b },
+	">":  func(a, b int64) bool { return a > b },
+	">=": func(a, b int64) bool { return a >= b },
+}
+
+func isComparison(op string) bool {
+	return comparisons[op] != nil || op == "~" || op == "!~"
+}
+
+// stringField returns field values, numberField returns field value
+// and false if value is unknown.
+type (
+	stringField func(in *Input) []string
+	numberField func(in *Input) (int64, bool)
+)
+
+func lookupField(name string) (stringField, numberField) {
+	switch name {
+	case "method":
+		return func(in *Input) []string { return []string{in.Request.Method} }, nil
+	case "host":
+		return func(in *Input) []string { return []string{requestHost(in.Request)} }, nil
+	case "path":
+		return func(in *Input) []string { return []string{in.Request.URL.Path} }, nil
+	case "status":
+		return nil, func(in *Input) (int64, bool) { return int64(in.Status), in.Status != 0 }
+	case "request_length":
+		return nil, func(in *Input) (int64, bool) { return in.Request.ContentLength, in.Request.ContentLength >= 0 }
+	case "response_length":
+		return nil, func(in *Input) (int64, bool) {
+			n := responseContentLength(in.Header)
+			return n, n >= 0
+		}
+	case "latency":
+		return nil, func(in *Input) (int64, bool) {
+			start := httpdump.RequestStart(in.Request)
+			return int64(time.Since(start)), !start.IsZero()
+		}
+	}
+
+	prefix, name, ok := strings.Cut(name, ".")
+	if !ok || name == "" {
+		return nil, nil
+	}
+
+	switch prefix {
+	case "header":
+		return func(in *Input) []string { return in.Request.Header.Values(name) }, nil
+	case "response_header":
+		return func(in *Input) []string { return in.Header.Values(name) }, nil
+	case "query":
+		return func(in *Input) []string { return in.Request.URL.Query()[name] }, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,104 @@
+package filter_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hummerd/httpdump/filter"
+)
+
+func TestParse(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/users?user=42", nil)
+	req.Header.Set("X-Debug", "1")
+
+	in := &filter.Input{
+		Request: req,
+		Header:  http.Header{"Content-Length": {"2048"}},
+		Status:  http.StatusBadGateway,
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`status >= 500 && path ~ "^/api"`, true},
+		{`status >= 500 && path !~ "^/api"`, false},
+		{`status < 500 || method == "get"`, true},
+		{`method != "GET"`, false},
+		{`host == "EXAMPLE.com"`, true},
+		{`path == "/api/users"`, true},
+		{`header.X-Debug && query.user == "42"`, true},
+		{`header.X-Trace || query.user != "42"`, false},
+		{`!(response_header.Content-Length) || response_length > 1024`, true},
+		{`request_length > 0`, false},
+		{`response_length <= 2048 && status == 502`, true},
+		{`latency > 0s`, false},
+		{`status == 502 || status == 503 && method == "POST"`, true},
+		{`(status == 502 || status == 503) && method == "POST"`, false},
+		{"path ~ `^/api/[a-z]+$`", true},
+		{`! ! header.x-debug`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := filter.Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := f(in); actual != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		expr   string
+		offset int
+	}{
+		{``, 0},
+		{`status >= 500 &&`, 16},
+		{`status >= "500"`, 10},
+		{`latency > 5`, 10},
+		{`path > "/"`, 5},
+		{`path ~ "["`, 7},
+		{`method`, 6},
+		{`unknown == "a"`, 0},
+		{`header. == "a"`, 0},
+		{`(status == 500`, 14},
+		{`status == 500 status`, 14},
+		{`path == "/api`, 8},
+		{`status == 500 # comment`, 14},
+		{`status ~ 500`, 7},
+		{`latency !~ "1s"`, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := filter.Parse(tt.expr)
+
+			var se *filter.SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("Expected syntax error, got %v", err)
+			}
+
+			if se.Offset != tt.offset {
+				t.Errorf("Expected error at offset %d, got %v", tt.offset, se)
+			}
+		})
+	}
+}
+
+func TestMustParse(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic for invalid expression")
+		}
+	}()
+
+	filter.MustParse("status >=")
+}
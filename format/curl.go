@@ -0,0 +1,149 @@
+// Package format renders dumped requests and responses as curl commands
+// and raw HTTP/1.1 messages.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hummerd/httpdump"
+)
+
+// Curl renders request as curl command line. Body is considered
+// truncated if dumped request body is reported truncated (see httpdump.RequestBodySize)
+// or it is shorter than request ContentLength, in that case command is preceded
+// by a shell comment.
+func Curl(r *http.Request, body []byte) string {
+	_, truncated := httpdump.RequestBodySize(r)
+	return curl(r, body, truncated || r.ContentLength > int64(len(body)))
+}
+
+// CurlExchange renders request of dumped exchange as curl command line.
+func CurlExchange(ex *httpdump.Exchange) string {
+	return curl(ex.Request, ex.RequestBody, ex.RequestTruncated)
+}
+
+func curl(r *http.Request, body []byte, truncated bool) string {
+	sb := &strings.Builder{}
+
+	if truncated {
+		fmt.Fprintf(sb, "# request body is truncated to %d bytes\n", len(body))
+	}
+
+	// shell arguments can not contain some bytes,
+	// so binary body is passed to curl with printf
+	binary := !utf8.Valid(body) || bytes.ContainsFunc(body, isControl)
+	if binary {
+		sb.WriteString("printf ")
+		sb.WriteString(shellQuote(printfEscape(body)))
+		sb.WriteString(" | ")
+	}
+
+	sb.WriteString("curl")
+
+	// curl uses GET without body and POST with body by default
+	method := http.MethodGet
+	if len(body) > 0 {
+		method = http.MethodPost
+	}
+
+	switch {
+	case r.Method == http.MethodHead:
+		// curl waits for response body if HEAD is set with -X
+		sb.WriteString(" -I")
+	case r.Method != method:
+		sb.WriteString(" -X ")
+		sb.WriteString(shellQuote(r.Method))
+	}
+
+	u := requestURL(r)
+	sb.WriteString(" ")
+	sb.WriteString(shellQuote(u.String()))
+
+	keys := make([]string, 0, len(r.Header))
+	for k := range r.Header {
+		// curl sets length itself
+		if k == "Content-Length" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if r.Host != "" && r.Host != u.Host {
+		writeCurlArg(sb, "-H", "Host: "+r.Host)
+	}
+
+	for _, k := range keys {
+		for _, v := range r.Header[k] {
+			writeCurlArg(sb, "-H", k+": "+v)
+		}
+	}
+
+	switch {
+	case binary:
+		writeCurlArg(sb, "--data-binary", "@-")
+	case len(body) > 0:
+		// unlike --data-binary, body starting with @ is not read from file
+		writeCurlArg(sb, "--data-raw", string(body))
+	}
+
+	return sb.String()
+}
+
+func writeCurlArg(sb *strings.Builder, name, value string) {
+	sb.WriteString(" \\\n  ")
+	sb.WriteString(name)
+	sb.WriteString(" ")
+	sb.WriteString(shellQuote(value))
+}
+
+// shellQuote quotes string for POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 && r != '\n' && r != '\r' && r != '\t' || r == 0x7f
+}
+
+// printfEscape escapes data to be used as printf format.
+func printfEscape(data []byte) string {
+	sb := &strings.Builder{}
+
+	for _, c := range data {
+		switch {
+		case c == '%':
+			sb.WriteString("%%")
+		case c == '\\':
+			sb.WriteString(`\\`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(sb, `\%03o`, c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String()
+}
+
+func requestURL(r *http.Request) *url.URL {
+	if r.URL.IsAbs() {
+		return r.URL
+	}
+
+	// server requests have only path in URL
+	u := *r.URL
+	u.Host = r.Host
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+
+	return &u
+}
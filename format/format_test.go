@@ -0,0 +1,143 @@
+package format_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/format"
+)
+
+func TestCurl(t *testing.T) {
+	body := `{"name":"it's me"}`
+
+	req := httptest.NewRequest(http.MethodPut, "/users/1?x=1", strings.NewReader(body))
+	req.Header.Set("Content-Type", httpdump.MimeApplicationJSON)
+	req.Header.Set("X-Tag", "a")
+	req.Header.Add("X-Tag", "b")
+
+	expected := `curl -X 'PUT' 'http://example.com/users/1?x=1' \
+  -H 'Content-Type: application/json' \
+  -H 'X-Tag: a' \
+  -H 'X-Tag: b' \
+  --data-raw '{"name":"it'\''s me"}'`
+
+	if c := format.Curl(req, []byte(body)); c != expected {
+		t.Errorf("Unexpected curl command:\n%s", c)
+	}
+
+	c := format.Curl(req, []byte(body[:5]))
+	if !strings.HasPrefix(c, "# request body is truncated to 5 bytes\ncurl") {
+		t.Errorf("Expected truncated body note:\n%s", c)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	if c := format.Curl(req, nil); c != `curl 'http://example.com/'` {
+		t.Errorf("Unexpected curl command for GET request:\n%s", c)
+	}
+}
+
+func TestCurl_Special(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("@/etc/passwd"))
+	if c := format.Curl(req, []byte("@/etc/passwd")); !strings.HasSuffix(c, "--data-raw '@/etc/passwd'") {
+		t.Errorf("Expected body starting with @ not to be read from file:\n%s", c)
+	}
+
+	req = httptest.NewRequest(http.MethodHead, "/", http.NoBody)
+	if c := format.Curl(req, nil); c != `curl -I 'http://example.com/'` {
+		t.Errorf("Unexpected curl command for HEAD request:\n%s", c)
+	}
+
+	var dumped string
+
+	m := httpdump.NewMiddleware(func(r *http.Request, body []byte) {
+		dumped = format.Curl(r, body)
+	}, nil, httpdump.WithLimitedBody(5))
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// body of unknown length
+	req = httptest.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader("chunked body")))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", httpdump.MimeTextPlain)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.HasPrefix(dumped, "# request body is truncated to 5 bytes\ncurl") {
+		t.Errorf("Expected truncated body note for body of unknown length:\n%s", dumped)
+	}
+}
+
+func TestCurl_BinaryBody(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+
+	body := "it's 100%\x00binary\\n\xff"
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c := format.Curl(req, []byte(body))
+
+	i := strings.Index(c, " | curl")
+	if !strings.HasPrefix(c, "printf ") || i < 0 || !strings.HasSuffix(c, "--data-binary '@-'") {
+		t.Fatalf("Expected binary body to be piped to curl:\n%s", c)
+	}
+
+	out, err := exec.Command(sh, "-c", c[:i]).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != body {
+		t.Errorf("Body is not escaped correctly %q", out)
+	}
+}
+
+func TestRaw(t *testing.T) {
+	var ex *httpdump.Exchange
+
+	m := httpdump.NewMiddleware(nil, nil, httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+		ex = e
+	}))
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpdump.MimeTextPlain)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/items?id=1", strings.NewReader("item"))
+	req.Header.Set("Content-Type", httpdump.MimeTextPlain)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := "POST /items?id=1 HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Content-Length: 4\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"item" +
+		"\r\n\r\n" +
+		"HTTP/1.1 201 Created\r\n" +
+		"Content-Length: 7\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"created"
+
+	if raw := string(format.RawExchange(ex)); raw != expected {
+		t.Errorf("Unexpected raw exchange:\n%q", raw)
+	}
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Length": []string{"100"}},
+	}
+
+	expected = "HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\nprefix"
+	if raw := string(format.RawResponse(resp, []byte("prefix"))); raw != expected {
+		t.Errorf("Unexpected raw response:\n%q", raw)
+	}
+}
@@ -0,0 +1,85 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hummerd/httpdump"
+)
+
+// RawRequest renders request as HTTP/1.1 message with captured body prefix.
+// Unlike httputil.DumpRequest it does not read request body.
+func RawRequest(r *http.Request, body []byte) []byte {
+	buff := &bytes.Buffer{}
+	writeRawRequest(buff, r, body)
+	return buff.Bytes()
+}
+
+// RawResponse renders response as HTTP/1.1 message with captured body prefix.
+// Unlike httputil.DumpResponse it does not read response body.
+func RawResponse(r *http.Response, body []byte) []byte {
+	buff := &bytes.Buffer{}
+	writeRawResponse(buff, r.StatusCode, r.Header, r.ContentLength, body)
+	return buff.Bytes()
+}
+
+// RawExchange renders request and response of dumped exchange
+// as HTTP/1.1 messages separated by empty line.
+func RawExchange(ex *httpdump.Exchange) []byte {
+	buff := &bytes.Buffer{}
+
+	writeRawRequest(buff, ex.Request, ex.RequestBody)
+	buff.WriteString("\r\n\r\n")
+	writeRawResponse(buff, ex.StatusCode, ex.ResponseHeader, ex.ResponseBytes, ex.ResponseBody)
+
+	return buff.Bytes()
+}
+
+func writeRawRequest(buff *bytes.Buffer, r *http.Request, body []byte) {
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+
+	fmt.Fprintf(buff, "%s %s HTTP/1.1\r\n", r.Method, uri)
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	if host != "" {
+		fmt.Fprintf(buff, "Host: %s\r\n", host)
+	}
+
+	writeRawHeaders(buff, r.Header, r.ContentLength, r.TransferEncoding)
+	buff.Write(body)
+}
+
+func writeRawResponse(
+	buff *bytes.Buffer,
+	status int,
+	headers http.Header,
+	contentLength int64,
+	body []byte,
+) {
+	fmt.Fprintf(buff, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+	writeRawHeaders(buff, headers, contentLength, nil)
+	buff.Write(body)
+}
+
+func writeRawHeaders(buff *bytes.Buffer, headers http.Header, contentLength int64, te []string) {
+	// client requests and dumped responses keep length out of headers
+	if headers.Get("Content-Length") == "" && headers.Get("Transfer-Encoding") == "" {
+		if len(te) > 0 {
+			fmt.Fprintf(buff, "Transfer-Encoding: %s\r\n", te[len(te)-1])
+		} else if contentLength > 0 {
+			fmt.Fprintf(buff, "Content-Length: %s\r\n", strconv.FormatInt(contentLength, 10))
+		}
+	}
+
+	_ = headers.Write(buff)
+	buff.WriteString("\r\n")
+}
@@ -0,0 +1,95 @@
+package httpdump
+
+import (
+	stdio "io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hummerd/httpdump/io"
+)
+
+// Body is a complete captured body, see WithFullBody.
+// It is valid only until dump function returns.
+type Body interface {
+	stdio.ReaderAt
+	Size() int64
+}
+
+// WithFullBody creates a new option that captures complete request and response bodies
+// in addition to limited prefixes. First memLimit bytes of body are kept in pooled
+// memory buffer, the rest is written to a temporary file in dir (os.TempDir if dir is empty).
+//
+// Complete bodies are passed to dump functions as Exchange.FullRequestBody,
+// Exchange.FullResponseBody and as Body of dumped response. Request is dumped
+// before it is handled, so request dump function receives only limited prefix.
+// Complete bodies cannot be redacted, so NewMiddleware panics if the option is used
+// together with WithRedaction. Temporary files are removed when dump functions return.
+func WithFullBody(memLimit int, dir string) Option {
+	if memLimit < 0 {
+		panic("httpdump: memory limit must not be negative")
+	}
+
+	return func(m *Middleware) {
+		m.spillPool = &sync.Pool{
+			New: func() any {
+				return io.NewSpillBuffer(memLimit, dir)
+			},
+		}
+	}
+}
+
+// spilledBodies are complete bodies of single exchange,
+// they are returned to pool when all dumps using them are done.
+type spilledBodies struct {
+	pool     *sync.Pool
+	request  *io.SpillBuffer
+	response *io.SpillBuffer
+	refs     atomic.Int32
+}
+
+func (m *Middleware) newSpilledBodies() *spilledBodies {
+	if m.spillPool == nil {
+		return nil
+	}
+
+	sb := &spilledBodies{pool: m.spillPool}
+	sb.refs.Store(1)
+
+	return sb
+}
+
+func (sb *spilledBodies) buffer() *io.SpillBuffer {
+	return sb.pool.Get().(*io.SpillBuffer)
+}
+
+func (sb *spilledBodies) acquire() {
+	if sb != nil {
+		sb.refs.Add(1)
+	}
+}
+
+func (sb *spilledBodies) release() {
+	if sb == nil || sb.refs.Add(-1) > 0 {
+		return
+	}
+
+	for _, b := range []*io.SpillBuffer{sb.request, sb.response} {
+		if b == nil {
+			continue
+		}
+
+		// nothing can be done if temporary file
+		// can not be removed, buffer is dropped in that case
+		if b.Reset() == nil {
+			sb.pool.Put(b)
+		}
+	}
+}
+
+// fullBody returns complete body captured by spill buffer.
+func fullBody(b *io.SpillBuffer) Body {
+	if b == nil {
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,103 @@
+package httpdump_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_FullBody(t *testing.T) {
+	reqBody := strings.Repeat("request ", 1000)
+	respBody := strings.Repeat("response ", 1000)
+
+	for _, async := range []bool{false, true} {
+		dir := t.TempDir()
+
+		var (
+			fullReq, fullResp, dumpedResp string
+			spilled                       int
+		)
+
+		opts := []httpdump.Option{
+			httpdump.WithFullBody(64, dir),
+			httpdump.WithDumpExchange(func(ex *httpdump.Exchange) {
+				files, _ := os.ReadDir(dir)
+				spilled = len(files)
+
+				fullReq = readFull(t, ex.FullRequestBody)
+				fullResp = readFull(t, ex.FullResponseBody)
+			}),
+		}
+		if async {
+			opts = append(opts, httpdump.WithAsyncDump(10, 1, httpdump.PolicyBlock))
+		}
+
+		m := httpdump.NewMiddleware(
+			nil,
+			func(rp *http.Response, _ []byte, _ time.Duration) {
+				b, err := io.ReadAll(rp.Body)
+				noerr(t, err)
+				dumpedResp = string(b)
+			},
+			opts...)
+
+		h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := io.ReadAll(r.Body)
+			noerr(t, err)
+
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(respBody))
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "text/plain")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		noerr(t, m.Shutdown(context.Background()))
+
+		if spilled != 2 {
+			t.Errorf("Expected both bodies to be spilled to disk while dumped, got %d files", spilled)
+		}
+
+		if fullReq != reqBody || fullResp != respBody || dumpedResp != respBody {
+			t.Errorf("Unexpected full bodies %d %d %d", len(fullReq), len(fullResp), len(dumpedResp))
+		}
+
+		files, _ := os.ReadDir(dir)
+		if len(files) != 0 {
+			t.Errorf("Expected temporary files to be removed after dump, got %d", len(files))
+		}
+	}
+}
+
+func readFull(t *testing.T, b httpdump.Body) string {
+	t.Helper()
+
+	if b == nil {
+		t.Fatal("Expected full body")
+	}
+
+	d, err := io.ReadAll(io.NewSectionReader(b, 0, b.Size()))
+	noerr(t, err)
+
+	return string(d)
+}
+
+func TestMiddleware_FullBodyWithRedaction(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic for full body capture with redaction")
+		}
+	}()
+
+	httpdump.NewMiddleware(nil, nil,
+		httpdump.WithFullBody(1024, ""),
+		httpdump.WithRedaction(httpdump.RedactJSONPaths("token")))
+}
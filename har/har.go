@@ -0,0 +1,312 @@
+// Package har converts dumped requests and responses to HAR 1.2 entries.
+// See http://www.softwareishard.com/blog/har-12-spec/ for format details.
+package har
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hummerd/httpdump"
+)
+
+const (
+	Version = "1.2"
+
+	EncodingBase64 = "base64"
+)
+
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+	Comment string  `json:"comment,omitempty"`
+}
+
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	// Time is a total elapsed time of the request in milliseconds.
+	Time     float64  `json:"time"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+	Cache    Cache    `json:"cache"`
+	Timings  Timings  `json:"timings"`
+	Comment  string   `json:"comment,omitempty"`
+}
+
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+	Comment     string      `json:"comment,omitempty"`
+}
+
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+	Comment     string      `json:"comment,omitempty"`
+}
+
+type Cookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type PostData struct {
+	MimeType string      `json:"mimeType"`
+	Params   []NameValue `json:"params,omitempty"`
+	Text     string      `json:"text"`
+	// Encoding is not a part of HAR 1.2 postData, but is widely supported
+	// by tools for binary request bodies.
+	Encoding  string `json:"encoding,omitempty"`
+	Truncated bool   `json:"_truncated,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+type Content struct {
+	Size      int64  `json:"size"`
+	MimeType  string `json:"mimeType"`
+	Text      string `json:"text,omitempty"`
+	Encoding  string `json:"encoding,omitempty"`
+	Truncated bool   `json:"_truncated,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+}
+
+type Cache struct{}
+
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// NewEntry creates a new HAR entry from dumped request and response.
+// Body limit is used to detect truncated bodies when their length is unknown.
+func NewEntry(
+	rq *http.Request,
+	reqBody []byte,
+	rp *http.Response,
+	respBody []byte,
+	duration time.Duration,
+	started time.Time,
+	bodyLimit int,
+) Entry {
+	ms := float64(duration) / float64(time.Millisecond)
+
+	return Entry{
+		StartedDateTime: started,
+		Time:            ms,
+		Request:         newRequest(rq, reqBody, bodyLimit),
+		Response:        newResponse(rp, respBody, bodyLimit),
+		Timings: Timings{
+			Wait: ms,
+		},
+	}
+}
+
+func newRequest(r *http.Request, body []byte, bodyLimit int) Request {
+	hr := Request{
+		Method:      r.Method,
+		URL:         requestURL(r).String(),
+		HTTPVersion: r.Proto,
+		Cookies:     requestCookies(r),
+		Headers:     nameValues(r.Header),
+		QueryString: nameValues(r.URL.Query()),
+		HeadersSize: -1,
+		BodySize:    r.ContentLength,
+	}
+
+	if body == nil {
+		return hr
+	}
+
+	text, encoding := bodyText(body)
+	truncated := isTruncated(body, r.ContentLength, bodyLimit)
+
+	hr.PostData = &PostData{
+		MimeType:  r.Header.Get(httpdump.HeaderContentType),
+		Text:      text,
+		Encoding:  encoding,
+		Truncated: truncated,
+		Comment:   truncatedComment(truncated, body),
+	}
+
+	return hr
+}
+
+func newResponse(r *http.Response, body []byte, bodyLimit int) Response {
+	size := responseSize(r)
+
+	hr := Response{
+		Status:      r.StatusCode,
+		StatusText:  http.StatusText(r.StatusCode),
+		HTTPVersion: r.Proto,
+		Cookies:     responseCookies(r),
+		Headers:     nameValues(r.Header),
+		RedirectURL: r.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    size,
+		Content: Content{
+			Size:     size,
+			MimeType: r.Header.Get(httpdump.HeaderContentType),
+		},
+	}
+
+	if body == nil {
+		return hr
+	}
+
+	text, encoding := bodyText(body)
+	truncated := isTruncated(body, size, bodyLimit)
+
+	hr.Content.Text = text
+	hr.Content.Encoding = encoding
+	hr.Content.Truncated = truncated
+	hr.Content.Comment = truncatedComment(truncated, body)
+
+	if size < 0 && !truncated {
+		hr.BodySize = int64(len(body))
+		hr.Content.Size = hr.BodySize
+	}
+
+	return hr
+}
+
+// responseSize returns response body size if it is known and -1 otherwise.
+func responseSize(r *http.Response) int64 {
+	if r.ContentLength > 0 {
+		return r.ContentLength
+	}
+
+	cl, err := strconv.ParseInt(r.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return cl
+}
+
+func requestURL(r *http.Request) *url.URL {
+	if r.URL.IsAbs() {
+		return r.URL
+	}
+
+	// server requests have only path in URL
+	u := *r.URL
+	u.Host = r.Host
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+
+	return &u
+}
+
+func requestCookies(r *http.Request) []Cookie {
+	cookies := r.Cookies()
+	hc := make([]Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		hc = append(hc, Cookie{
+			Name:  c.Name,
+			Value: c.Value,
+		})
+	}
+	return hc
+}
+
+func responseCookies(r *http.Response) []Cookie {
+	cookies := r.Cookies()
+	hc := make([]Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		hcc := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+
+		if !c.Expires.IsZero() {
+			e := c.Expires
+			hcc.Expires = &e
+		}
+
+		hc = append(hc, hcc)
+	}
+	return hc
+}
+
+func nameValues(m map[string][]string) []NameValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	nv := make([]NameValue, 0, len(m))
+	for _, k := range keys {
+		for _, v := range m[k] {
+			nv = append(nv, NameValue{Name: k, Value: v})
+		}
+	}
+	return nv
+}
+
+func bodyText(body []byte) (text, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), EncodingBase64
+}
+
+func isTruncated(body []byte, size int64, bodyLimit int) bool {
+	if size >= 0 {
+		return int64(len(body)) < size
+	}
+	return bodyLimit > 0 && len(body) >= bodyLimit
+}
+
+func truncatedComment(truncated bool, body []byte) string {
+	if !truncated {
+		return ""
+	}
+	return fmt.Sprintf("body truncated to %d bytes", len(body))
+}
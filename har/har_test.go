@@ -0,0 +1,210 @@
+package har_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/har"
+)
+
+func TestWriter_Middleware(t *testing.T) {
+	buff := &bytes.Buffer{}
+	hw := har.NewWriter(buff, har.WithBodyLimit(10))
+
+	m := httpdump.NewMiddleware(
+		hw.DumpRequest,
+		hw.DumpResponse,
+		httpdump.WithLimitedBody(10))
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+		w.Write([]byte("this is response body"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/call_me?q=1", strings.NewReader(`{"a":1}`))
+	req.Header.Set("Content-Type", httpdump.MimeApplicationJSON)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/call_me", http.NoBody)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := hw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var f har.HAR
+	if err := json.Unmarshal(buff.Bytes(), &f); err != nil {
+		t.Fatal(err, buff.String())
+	}
+
+	if f.Log.Version != har.Version {
+		t.Errorf("Expected version %s, got %s", har.Version, f.Log.Version)
+	}
+
+	if len(f.Log.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(f.Log.Entries))
+	}
+
+	e := f.Log.Entries[0]
+
+	if e.Request.Method != http.MethodPost || e.Request.URL != "http://example.com/call_me?q=1" {
+		t.Errorf("Unexpected request %s %s", e.Request.Method, e.Request.URL)
+	}
+
+	if len(e.Request.QueryString) != 1 || e.Request.QueryString[0] != (har.NameValue{Name: "q", Value: "1"}) {
+		t.Errorf("Unexpected query string %v", e.Request.QueryString)
+	}
+
+	if e.Request.PostData == nil ||
+		e.Request.PostData.Text != `{"a":1}` ||
+		e.Request.PostData.MimeType != httpdump.MimeApplicationJSON ||
+		e.Request.PostData.Truncated {
+		t.Errorf("Unexpected post data %+v", e.Request.PostData)
+	}
+
+	c := e.Response.Content
+	if c.Text != "this is re" || !c.Truncated || c.Size != 21 || c.MimeType != "text/plain" {
+		t.Errorf("Unexpected response content %+v", c)
+	}
+
+	if len(e.Response.Cookies) != 1 || e.Response.Cookies[0].Name != "session" {
+		t.Errorf("Unexpected response cookies %+v", e.Response.Cookies)
+	}
+
+	e = f.Log.Entries[1]
+	if e.Request.PostData != nil {
+		t.Errorf("Expected no post data for GET request, got %+v", e.Request.PostData)
+	}
+}
+
+func TestWriter_Empty(t *testing.T) {
+	buff := &bytes.Buffer{}
+	hw := har.NewWriter(buff)
+
+	if err := hw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var f har.HAR
+	if err := json.Unmarshal(buff.Bytes(), &f); err != nil {
+		t.Fatal(err, buff.String())
+	}
+
+	if len(f.Log.Entries) != 0 || f.Log.Creator.Name != "httpdump" {
+		t.Errorf("Unexpected empty HAR %+v", f)
+	}
+
+	if err := hw.WriteEntry(&har.Entry{}); err != har.ErrClosed {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestNewEntry_BinaryBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "https://example.com/upload", nil)
+	req.ContentLength = 4
+
+	resp := &http.Response{
+		StatusCode: http.StatusCreated,
+		Proto:      "HTTP/1.1",
+		Header:     http.Header{"Content-Length": []string{"0"}},
+	}
+
+	e := har.NewEntry(req, []byte{0xff, 0xfe, 0x00, 0x01}, resp, []byte{}, time.Second, time.Now(), 0)
+
+	pd := e.Request.PostData
+	if pd == nil || pd.Encoding != har.EncodingBase64 || pd.Text != "//4AAQ==" || pd.Truncated {
+		t.Errorf("Unexpected post data %+v", pd)
+	}
+
+	if e.Request.URL != "https://example.com/upload" {
+		t.Errorf("Unexpected URL %s", e.Request.URL)
+	}
+
+	if e.Response.StatusText != "Created" || e.Response.BodySize != 0 {
+		t.Errorf("Unexpected response %+v", e.Response)
+	}
+
+	if e.Time != 1000 {
+		t.Errorf("Expected time 1000ms, got %v", e.Time)
+	}
+}
+
+func TestWriter_DumpExchange(t *testing.T) {
+	buff := &bytes.Buffer{}
+	hw := har.NewWriter(buff)
+
+	m := httpdump.NewMiddleware(nil, nil, httpdump.WithDumpExchange(hw.DumpExchange))
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("pong"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/ping", strings.NewReader("ping"))
+	req.Header.Set("Content-Type", "text/plain")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := hw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var f har.HAR
+	if err := json.Unmarshal(buff.Bytes(), &f); err != nil {
+		t.Fatal(err, buff.String())
+	}
+
+	if len(f.Log.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(f.Log.Entries))
+	}
+
+	e := f.Log.Entries[0]
+	if e.Request.PostData == nil || e.Request.PostData.Text != "ping" {
+		t.Errorf("Unexpected post data %+v", e.Request.PostData)
+	}
+
+	if e.Response.Content.Text != "pong" || e.Response.Content.Size != 4 {
+		t.Errorf("Unexpected content %+v", e.Response.Content)
+	}
+}
+
+func TestWriter_PendingEviction(t *testing.T) {
+	hw := har.NewWriter(&bytes.Buffer{}, har.WithPendingLimit(2), har.WithPendingTTL(50*time.Millisecond))
+
+	m := httpdump.NewMiddleware(
+		hw.DumpRequest,
+		hw.DumpResponse,
+		httpdump.WithResponseFilters(func(r *http.Request, _ http.Header, _ int) (bool, bool) {
+			return r.URL.Path != "/filtered", true
+		}))
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(p string) {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, http.NoBody))
+	}
+
+	for i := 0; i < 5; i++ {
+		serve("/filtered")
+	}
+
+	if n := hw.PendingRequests(); n != 2 {
+		t.Errorf("Expected pending requests to be limited by 2, got %d", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	serve("/ok")
+
+	if n := hw.PendingRequests(); n != 0 {
+		t.Errorf("Expected expired pending requests to be discarded, got %d", n)
+	}
+
+	if err := hw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,277 @@
+package har
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+var ErrClosed = errors.New("har: writer is closed")
+
+const (
+	// DefaultPendingLimit is a default max number of requests waiting for response.
+	DefaultPendingLimit = 1000
+	// DefaultPendingTTL is a default time request waits for response.
+	DefaultPendingTTL = time.Minute
+)
+
+// Option is a writer option that allows to override default writer settings.
+type Option func(*Writer)
+
+// WithCreator creates a new option that sets HAR creator.
+func WithCreator(name, version string) Option {
+	return func(w *Writer) {
+		w.creator = Creator{Name: name, Version: version}
+	}
+}
+
+// WithBodyLimit creates a new option that sets body limit used by middleware,
+// it is used to detect truncated bodies when body size is unknown.
+func WithBodyLimit(limit int) Option {
+	return func(w *Writer) {
+		w.bodyLimit = limit
+	}
+}
+
+// WithPendingLimit creates a new option that sets max number of requests
+// dumped by DumpRequest that wait for response, the oldest request is discarded
+// when limit is reached.
+func WithPendingLimit(limit int) Option {
+	if limit <= 0 {
+		panic("har: pending limit must be greater than 0")
+	}
+
+	return func(w *Writer) {
+		w.pendingLimit = limit
+	}
+}
+
+// WithPendingTTL creates a new option that sets time request dumped by DumpRequest
+// waits for response, request is discarded if response is not dumped in time.
+func WithPendingTTL(ttl time.Duration) Option {
+	if ttl <= 0 {
+		panic("har: pending ttl must be greater than 0")
+	}
+
+	return func(w *Writer) {
+		w.pendingTTL = ttl
+	}
+}
+
+// Writer streams HAR entries to underlying io.Writer.
+// Entries are written as soon as they are added, so memory usage does not grow
+// with the number of entries. HAR file becomes complete when Writer is closed.
+type Writer struct {
+	mu        sync.Mutex
+	w         io.Writer
+	enc       *json.Encoder
+	creator   Creator
+	bodyLimit int
+	entries   int
+	closed    bool
+	err       error
+
+	// pending requests are ordered by start time, so expired ones are at front
+	pending      map[*http.Request]*list.Element
+	pendingOrder *list.List
+	pendingLimit int
+	pendingTTL   time.Duration
+}
+
+type pendingRequest struct {
+	rq      *http.Request
+	body    []byte
+	started time.Time
+}
+
+// NewWriter creates a new HAR writer.
+func NewWriter(w io.Writer, opts ...Option) *Writer {
+	hw := &Writer{
+		w:         w,
+		enc:       json.NewEncoder(w),
+		creator:   Creator{Name: "httpdump"},
+		bodyLimit: httpdump.DefaultBodySize,
+
+		pending:      make(map[*http.Request]*list.Element),
+		pendingOrder: list.New(),
+		pendingLimit: DefaultPendingLimit,
+		pendingTTL:   DefaultPendingTTL,
+	}
+
+	for _, opt := range opts {
+		opt(hw)
+	}
+
+	return hw
+}
+
+// DumpRequest is a httpdump.DumpRequestFunc that saves request body
+// until response for this request is dumped. Requests dumped without response
+// (for example filtered by response filters) are discarded when they wait for response
+// longer than pending ttl or there are too many of them, see WithPendingLimit and
+// WithPendingTTL. Prefer DumpExchange that does not need to keep pending requests.
+func (hw *Writer) DumpRequest(rq *http.Request, body []byte) {
+	// body may be reused by middleware after dump, so copy it
+	pr := &pendingRequest{
+		rq:      rq,
+		started: time.Now(),
+	}
+	if body != nil {
+		pr.body = append([]byte{}, body...)
+	}
+
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+
+	if hw.closed {
+		return
+	}
+
+	hw.evictPending(pr.started)
+	hw.pending[rq] = hw.pendingOrder.PushBack(pr)
+}
+
+// PendingRequests returns number of requests waiting for response.
+func (hw *Writer) PendingRequests() int {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+
+	return len(hw.pending)
+}
+
+// evictPending discards expired requests and the oldest ones over limit
+// to free space for a new request.
+func (hw *Writer) evictPending(now time.Time) {
+	for e := hw.pendingOrder.Front(); e != nil; e = hw.pendingOrder.Front() {
+		pr := e.Value.(*pendingRequest)
+		if len(hw.pending) < hw.pendingLimit && now.Sub(pr.started) < hw.pendingTTL {
+			return
+		}
+
+		hw.removePending(e)
+	}
+}
+
+func (hw *Writer) removePending(e *list.Element) *pendingRequest {
+	pr := hw.pendingOrder.Remove(e).(*pendingRequest)
+	delete(hw.pending, pr.rq)
+	return pr
+}
+
+// DumpResponse is a httpdump.DumpResponseFunc that writes HAR entry for
+// dumped response and previously dumped request.
+func (hw *Writer) DumpResponse(rp *http.Response, body []byte, duration time.Duration) {
+	var pr pendingRequest
+
+	hw.mu.Lock()
+	el, ok := hw.pending[rp.Request]
+	if ok {
+		pr = *hw.removePending(el)
+	}
+	hw.mu.Unlock()
+
+	if !ok {
+		pr.started = time.Now().Add(-duration)
+	}
+
+	e := NewEntry(rp.Request, pr.body, rp, body, duration, pr.started, hw.bodyLimit)
+
+	// there is no way to return error from dump function,
+	// it is saved and returned by Close
+	_ = hw.WriteEntry(&e)
+}
+
+// DumpExchange is a httpdump.DumpExchangeFunc that writes HAR entry for dumped exchange.
+// Unlike DumpRequest and DumpResponse it does not need to keep pending requests.
+func (hw *Writer) DumpExchange(ex *httpdump.Exchange) {
+	resp := &http.Response{
+		Status:        http.StatusText(ex.StatusCode),
+		StatusCode:    ex.StatusCode,
+		Proto:         ex.Request.Proto,
+		ProtoMajor:    ex.Request.ProtoMajor,
+		ProtoMinor:    ex.Request.ProtoMinor,
+		Header:        ex.ResponseHeader,
+		ContentLength: ex.ResponseBytes,
+		Request:       ex.Request,
+	}
+
+	e := NewEntry(ex.Request, ex.RequestBody, resp, ex.ResponseBody, ex.Duration, ex.Start, hw.bodyLimit)
+
+	_ = hw.WriteEntry(&e)
+}
+
+// WriteEntry writes entry to underlying writer.
+func (hw *Writer) WriteEntry(e *Entry) error {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+
+	if hw.closed {
+		return ErrClosed
+	}
+
+	if hw.err != nil {
+		return hw.err
+	}
+
+	if hw.entries == 0 {
+		hw.err = hw.writeHeader()
+	} else {
+		_, hw.err = io.WriteString(hw.w, ",")
+	}
+
+	if hw.err != nil {
+		return hw.err
+	}
+
+	// encoder adds new line after each entry,
+	// so streamed file stays readable
+	hw.err = hw.enc.Encode(e)
+	hw.entries++
+
+	return hw.err
+}
+
+// Close finishes HAR file. It does not close underlying writer.
+// Returns first error occurred while writing entries.
+func (hw *Writer) Close() error {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+
+	if hw.closed {
+		return hw.err
+	}
+
+	hw.closed = true
+	hw.pending = nil
+	hw.pendingOrder.Init()
+
+	if hw.err != nil {
+		return hw.err
+	}
+
+	if hw.entries == 0 {
+		hw.err = hw.writeHeader()
+		if hw.err != nil {
+			return hw.err
+		}
+	}
+
+	_, hw.err = io.WriteString(hw.w, "]}}\n")
+	return hw.err
+}
+
+func (hw *Writer) writeHeader() error {
+	c, err := json.Marshal(hw.creator)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(hw.w, `{"log":{"version":"`+Version+`","creator":`+string(c)+`,"entries":[`+"\n")
+	return err
+}
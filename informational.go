@@ -0,0 +1,69 @@
+package httpdump
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderTrailer is a header that declares trailer names.
+const HeaderTrailer = "Trailer"
+
+// InformationalResponse is an informational (1xx) response,
+// such as 103 Early Hints, sent by handler before final response.
+type InformationalResponse struct {
+	StatusCode int
+	Header     http.Header
+}
+
+// InformationalResponses returns informational responses sent before dumped response.
+// It returns nil if there were no informational responses or response
+// was not dumped by middleware.
+func InformationalResponses(resp *http.Response) []InformationalResponse {
+	if resp.Request == nil {
+		return nil
+	}
+
+	st := stateFromRequest(resp.Request)
+	if st == nil {
+		return nil
+	}
+
+	return st.informational
+}
+
+// isInformational reports whether status is sent by http.ResponseWriter
+// as informational response, 101 Switching Protocols is a final response.
+func isInformational(status int) bool {
+	return status >= 100 && status <= 199 && status != http.StatusSwitchingProtocols
+}
+
+// responseTrailers returns trailers set by handler: values of names
+// declared in Trailer header and headers with http.TrailerPrefix.
+// It returns nil if there are no trailers.
+func responseTrailers(h http.Header) http.Header {
+	var trailers http.Header
+
+	add := func(k string, vs []string) {
+		if trailers == nil {
+			trailers = make(http.Header)
+		}
+		trailers[k] = append(trailers[k], vs...)
+	}
+
+	for _, v := range h[HeaderTrailer] {
+		for _, k := range strings.Split(v, ",") {
+			k = http.CanonicalHeaderKey(strings.TrimSpace(k))
+			if vs, ok := h[k]; ok && k != "" {
+				add(k, vs)
+			}
+		}
+	}
+
+	for k, vs := range h {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			add(http.CanonicalHeaderKey(strings.TrimPrefix(k, http.TrailerPrefix)), vs)
+		}
+	}
+
+	return trailers
+}
@@ -0,0 +1,84 @@
+package httpdump_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_TrailersAndInformational(t *testing.T) {
+	var (
+		resp *http.Response
+		ex   *httpdump.Exchange
+		info []httpdump.InformationalResponse
+	)
+
+	m := httpdump.NewMiddleware(
+		nil,
+		func(rp *http.Response, body []byte, _ time.Duration) {
+			resp = rp
+			info = httpdump.InformationalResponses(rp)
+		},
+		httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+			ex = e
+		}),
+	)
+
+	srv := httptest.NewServer(m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+
+		w.Header().Set("Link", "</style.css>; rel=preload")
+		w.WriteHeader(http.StatusEarlyHints)
+
+		w.Header().Set(httpdump.HeaderTrailer, "X-Checksum")
+		w.Header().Set(httpdump.HeaderContentType, httpdump.MimeTextPlain)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("response body"))
+
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set(http.TrailerPrefix+"X-Undeclared", "def")
+	})))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, io.MultiReader(strings.NewReader("request body")))
+	noerr(t, err)
+
+	req.Header.Set(httpdump.HeaderContentType, httpdump.MimeTextPlain)
+	req.Trailer = http.Header{"X-Request-Sum": {"42"}}
+
+	r, err := http.DefaultClient.Do(req)
+	noerr(t, err)
+
+	_, _ = io.ReadAll(r.Body)
+	r.Body.Close()
+
+	if resp == nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected final response to be dumped, got %+v", resp)
+	}
+
+	if resp.Trailer.Get("X-Checksum") != "abc" || resp.Trailer.Get("X-Undeclared") != "def" {
+		t.Errorf("Unexpected response trailer %v", resp.Trailer)
+	}
+
+	if len(info) != 1 || info[0].StatusCode != http.StatusEarlyHints ||
+		info[0].Header.Get("Link") != "</style.css>; rel=preload" || info[0].Header.Get(httpdump.HeaderTrailer) != "" {
+		t.Errorf("Unexpected informational responses %+v", info)
+	}
+
+	if ex == nil {
+		t.Fatal("Expected exchange to be dumped")
+	}
+
+	if ex.RequestTrailer.Get("X-Request-Sum") != "42" {
+		t.Errorf("Unexpected request trailer %v", ex.RequestTrailer)
+	}
+
+	if ex.ResponseTrailer.Get("X-Checksum") != "abc" || len(ex.Informational) != 1 {
+		t.Errorf("Unexpected exchange trailer %v and informational responses %+v", ex.ResponseTrailer, ex.Informational)
+	}
+}
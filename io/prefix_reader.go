@@ -23,11 +23,17 @@ type PrefixReader struct {
 	cached int
 	err    error
 	cache  []byte
+	tee    io.Writer
+	lazy   bool
+	n      int64
 }
 
 func (cr *PrefixReader) Reset(r io.Reader) error {
 	cr.r = r
 	cr.read = 0
+	cr.tee = nil
+	cr.lazy = false
+	cr.n = 0
 	n, err := io.ReadAtLeast(r, cr.cache, len(cr.cache))
 	cr.cached = n
 	if err == io.ErrUnexpectedEOF {
@@ -37,11 +43,64 @@ func (cr *PrefixReader) Reset(r io.Reader) error {
 	return err
 }
 
+// ResetLazy resets reader without reading prefix in advance,
+// prefix is captured while data is read from reader.
+func (cr *PrefixReader) ResetLazy(r io.Reader) {
+	cr.r = r
+	cr.read = 0
+	cr.cached = 0
+	cr.err = nil
+	cr.tee = nil
+	cr.lazy = true
+	cr.n = 0
+}
+
+// Resize changes prefix length, it must be called before Reset.
+func (cr *PrefixReader) Resize(prefixLen int) {
+	if cap(cr.cache) < prefixLen {
+		cr.cache = make([]byte, prefixLen)
+		return
+	}
+
+	cr.cache = cr.cache[:prefixLen]
+}
+
 func (cr *PrefixReader) Prefix() []byte {
 	return cr.cache[:cr.cached]
 }
 
+// BytesRead returns total number of bytes read from reader.
+func (cr *PrefixReader) BytesRead() int64 {
+	return cr.n
+}
+
+// Truncated reports whether more bytes were read from reader than fit in prefix.
+func (cr *PrefixReader) Truncated() bool {
+	return cr.n > int64(cr.cached)
+}
+
+// Tee makes reader to write all bytes read from it to w,
+// errors of w are ignored. Reset removes w.
+func (cr *PrefixReader) Tee(w io.Writer) {
+	cr.tee = w
+}
+
 func (cr *PrefixReader) Read(p []byte) (int, error) {
+	n, err := cr.readCached(p)
+	cr.n += int64(n)
+	if cr.tee != nil && n > 0 {
+		_, _ = cr.tee.Write(p[:n])
+	}
+	return n, err
+}
+
+func (cr *PrefixReader) readCached(p []byte) (int, error) {
+	if cr.lazy {
+		n, err := cr.r.Read(p)
+		cr.cached += copy(cr.cache[cr.cached:], p[:n])
+		return n, err
+	}
+
 	l := cr.cached - cr.read
 	c := 0
 	if l > 0 {
@@ -63,8 +122,22 @@ func (cr *PrefixReader) Read(p []byte) (int, error) {
 }
 
 func (cr *PrefixReader) WriteTo(w io.Writer) (n int64, err error) {
+	if cr.lazy {
+		// prefix is captured and bytes are counted by Read
+		return io.Copy(w, onlyReader{cr})
+	}
+
+	defer func() {
+		cr.n += n
+	}()
+
+	if cr.tee != nil {
+		w = io.MultiWriter(w, teeWriter{cr.tee})
+	}
+
 	if cr.cached-cr.read > 0 {
 		nn, err := w.Write(cr.cache[cr.read:cr.cached])
+		cr.read += nn
 		n = int64(nn)
 		if err != nil {
 			return int64(n), err
@@ -86,3 +159,19 @@ func (cr *PrefixReader) Close() error {
 	}
 	return nil
 }
+
+// onlyReader hides WriterTo implementation of underlying reader.
+type onlyReader struct {
+	io.Reader
+}
+
+// teeWriter ignores errors of underlying writer,
+// so capturing failure does not break data transfer.
+type teeWriter struct {
+	w io.Writer
+}
+
+func (tw teeWriter) Write(p []byte) (int, error) {
+	_, _ = tw.w.Write(p)
+	return len(p), nil
+}
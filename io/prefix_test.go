@@ -6,6 +6,7 @@ import (
 	stdio "io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 
@@ -371,3 +372,203 @@ func TestReadHttp(t *testing.T) {
 		t.Fatal("Read all failed", string(d), len(d))
 	}
 }
+
+func TestCachedWriter_ReaderFromShort(t *testing.T) {
+	cw := io.NewPrefixWriter(nil, 40)
+
+	buff := &bytes.Buffer{}
+	cw.Reset(buff)
+
+	s := "123456789012345678901234567890"
+	n, err := cw.ReadFrom(strings.NewReader(s))
+	if n != int64(len(s)) || err != nil {
+		t.Fatal("Can not write from short reader ", n, err)
+	}
+
+	n, err = cw.ReadFrom(strings.NewReader(""))
+	if n != 0 || err != nil {
+		t.Fatal("Can not write from empty reader ", n, err)
+	}
+
+	if string(cw.Prefix()) != s || buff.String() != s {
+		t.Fatal("Wrong data ", string(cw.Prefix()), buff.String())
+	}
+}
+
+func TestCachedReader_Tee(t *testing.T) {
+	s := "123456789012345678901234567890"
+
+	for _, writeTo := range []bool{false, true} {
+		cr, err := io.NewPrefixReader(strings.NewReader(s), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tee := &bytes.Buffer{}
+		cr.Tee(tee)
+
+		var d []byte
+		if writeTo {
+			buff := &bytes.Buffer{}
+			_, err = cr.WriteTo(buff)
+			d = buff.Bytes()
+		} else {
+			d, err = stdio.ReadAll(cr)
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(d) != s || tee.String() != s {
+			t.Fatal("Wrong tee data ", string(d), tee.String())
+		}
+	}
+}
+
+func TestCachedWriter_Tee(t *testing.T) {
+	cw := io.NewPrefixWriter(nil, 5)
+
+	buff := &bytes.Buffer{}
+	cw.Reset(buff)
+
+	tee := &bytes.Buffer{}
+	cw.Tee(tee)
+
+	_, _ = cw.Write([]byte("1234567"))
+	_, _ = cw.ReadFrom(strings.NewReader("890"))
+
+	if buff.String() != "1234567890" || tee.String() != "1234567890" {
+		t.Fatal("Wrong tee data ", buff.String(), tee.String())
+	}
+
+	cw.Reset(buff)
+	_, _ = cw.Write([]byte("x"))
+
+	if tee.String() != "1234567890" {
+		t.Fatal("Tee must be removed by Reset ", tee.String())
+	}
+}
+
+func TestSpillBuffer(t *testing.T) {
+	dir := t.TempDir()
+
+	b := io.NewSpillBuffer(4, dir)
+
+	s := "123456789012345678901234567890"
+	for i := 0; i < len(s); i += 7 {
+		_, err := b.Write([]byte(s[i:min(i+7, len(s))]))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if b.Size() != int64(len(s)) {
+		t.Fatal("Wrong size ", b.Size())
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatal("Expected data to be spilled to temporary file ", len(files))
+	}
+
+	d, err := stdio.ReadAll(stdio.NewSectionReader(b, 0, b.Size()))
+	if err != nil || string(d) != s {
+		t.Fatal("Wrong data ", string(d), err)
+	}
+
+	p := make([]byte, 6)
+	n, err := b.ReadAt(p, 2)
+	if n != 6 || err != nil || string(p) != s[2:8] {
+		t.Fatal("Wrong data across memory and file ", string(p[:n]), err)
+	}
+
+	n, err = b.ReadAt(p, int64(len(s)-3))
+	if n != 3 || err != stdio.EOF {
+		t.Fatal("Expected EOF at the end ", n, err)
+	}
+
+	err = b.Reset()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ = os.ReadDir(dir)
+	if len(files) != 0 || b.Size() != 0 {
+		t.Fatal("Expected temporary file to be removed ", len(files))
+	}
+}
+
+func TestCachedReader_Lazy(t *testing.T) {
+	cr, err := io.NewPrefixReader(nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := "123456789012345678901234567890"
+	cr.ResetLazy(strings.NewReader(s))
+
+	if len(cr.Prefix()) != 0 {
+		t.Fatal("Lazy reader must not read prefix in advance ", string(cr.Prefix()))
+	}
+
+	d := make([]byte, 4)
+	_, err = stdio.ReadFull(cr, d)
+	if err != nil || string(cr.Prefix()) != s[:4] {
+		t.Fatal("Wrong prefix after read ", string(cr.Prefix()), err)
+	}
+
+	buff := &bytes.Buffer{}
+	_, err = cr.WriteTo(buff)
+	if err != nil || buff.String() != s[4:] || string(cr.Prefix()) != s[:10] {
+		t.Fatal("Wrong data ", buff.String(), string(cr.Prefix()), err)
+	}
+}
+
+func TestCachedReader_BytesRead(t *testing.T) {
+	s := "123456789012345678901234567890"
+
+	cr, err := io.NewPrefixReader(strings.NewReader(s), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := make([]byte, 5)
+	_, _ = cr.Read(d)
+
+	if cr.BytesRead() != 5 || cr.Truncated() {
+		t.Fatal("Wrong counters after prefix read ", cr.BytesRead(), cr.Truncated())
+	}
+
+	_, err = cr.WriteTo(stdio.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cr.BytesRead() != int64(len(s)) || !cr.Truncated() {
+		t.Fatal("Wrong counters after read ", cr.BytesRead(), cr.Truncated())
+	}
+
+	_ = cr.Reset(strings.NewReader(s[:10]))
+	_, _ = stdio.ReadAll(cr)
+
+	if cr.BytesRead() != 10 || cr.Truncated() {
+		t.Fatal("Body that fits prefix must not be truncated ", cr.BytesRead(), cr.Truncated())
+	}
+}
+
+func TestCachedWriter_BytesWritten(t *testing.T) {
+	cw := io.NewPrefixWriter(stdio.Discard, 10)
+
+	_, _ = cw.Write([]byte("12345"))
+
+	if cw.BytesWritten() != 5 || cw.Truncated() {
+		t.Fatal("Wrong counters after write ", cw.BytesWritten(), cw.Truncated())
+	}
+
+	_, _ = cw.ReadFrom(strings.NewReader("1234567890"))
+
+	if cw.BytesWritten() != 15 || !cw.Truncated() {
+		t.Fatal("Wrong counters after read from ", cw.BytesWritten(), cw.Truncated())
+	}
+}
@@ -16,6 +16,18 @@ type PrefixWriter struct {
 	w      io.Writer
 	cached int
 	cache  []byte
+	tee    io.Writer
+	n      int64
+}
+
+// Resize changes prefix length, it must be called before Reset.
+func (pw *PrefixWriter) Resize(prefixLen int) {
+	if cap(pw.cache) < prefixLen {
+		pw.cache = make([]byte, prefixLen)
+		return
+	}
+
+	pw.cache = pw.cache[:prefixLen]
 }
 
 func (pw *PrefixWriter) Prefix() []byte {
@@ -25,6 +37,24 @@ func (pw *PrefixWriter) Prefix() []byte {
 func (pw *PrefixWriter) Reset(w io.Writer) {
 	pw.w = w
 	pw.cached = 0
+	pw.tee = nil
+	pw.n = 0
+}
+
+// BytesWritten returns total number of bytes written to writer.
+func (pw *PrefixWriter) BytesWritten() int64 {
+	return pw.n
+}
+
+// Truncated reports whether more bytes were written to writer than fit in prefix.
+func (pw *PrefixWriter) Truncated() bool {
+	return pw.n > int64(pw.cached)
+}
+
+// Tee makes writer to write all written bytes to w as well,
+// errors of w are ignored. Reset removes w.
+func (pw *PrefixWriter) Tee(w io.Writer) {
+	pw.tee = w
 }
 
 func (pw *PrefixWriter) Write(data []byte) (int, error) {
@@ -37,10 +67,23 @@ func (pw *PrefixWriter) Write(data []byte) (int, error) {
 		pw.cached += n
 	}
 
-	return pw.w.Write(data)
+	n, err := pw.w.Write(data)
+	pw.n += int64(n)
+	if pw.tee != nil && n > 0 {
+		_, _ = pw.tee.Write(data[:n])
+	}
+	return n, err
 }
 
 func (pw *PrefixWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	defer func() {
+		pw.n += n
+	}()
+
+	if pw.tee != nil {
+		r = io.TeeReader(r, teeWriter{pw.tee})
+	}
+
 	l := len(pw.cache) - pw.cached
 	if l > 0 {
 		nn, err := io.ReadAtLeast(r, pw.cache[pw.cached:], l)
@@ -55,8 +98,9 @@ func (pw *PrefixWriter) ReadFrom(r io.Reader) (n int64, err error) {
 		pw.cached += nn
 
 		if err != nil {
-			if err == io.ErrUnexpectedEOF {
-				return n, io.EOF
+			// ReadFrom reads until EOF, so EOF is not an error
+			if err == io.ErrUnexpectedEOF || err == io.EOF {
+				return n, nil
 			}
 			return n, err
 		}
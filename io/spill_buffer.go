@@ -0,0 +1,129 @@
+package io
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+var errNegativeOffset = errors.New("io: negative offset")
+
+// NewSpillBuffer creates a new buffer that keeps first memLimit bytes in memory
+// and writes the rest to a temporary file in dir (os.TempDir if dir is empty).
+func NewSpillBuffer(memLimit int, dir string) *SpillBuffer {
+	return &SpillBuffer{
+		mem:   make([]byte, 0, memLimit),
+		limit: memLimit,
+		dir:   dir,
+	}
+}
+
+// SpillBuffer is an append only buffer that spills data to disk.
+// Write must not be called concurrently with other methods,
+// ReadAt is safe to call concurrently when writing is finished.
+type SpillBuffer struct {
+	mem   []byte
+	limit int
+	dir   string
+	file  *os.File
+	size  int64
+	err   error
+}
+
+// Write appends data to buffer. After the first error buffer stops
+// capturing data and returns that error.
+func (b *SpillBuffer) Write(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	n := 0
+
+	if l := b.limit - len(b.mem); l > 0 {
+		if l > len(p) {
+			l = len(p)
+		}
+		b.mem = append(b.mem, p[:l]...)
+		n = l
+	}
+
+	if n < len(p) {
+		if b.file == nil {
+			b.file, b.err = os.CreateTemp(b.dir, "httpdump-*")
+			if b.err != nil {
+				b.size += int64(n)
+				return n, b.err
+			}
+		}
+
+		var m int
+		m, b.err = b.file.Write(p[n:])
+		n += m
+	}
+
+	b.size += int64(n)
+
+	return n, b.err
+}
+
+// ReadAt implements io.ReaderAt.
+func (b *SpillBuffer) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	if off >= b.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	ml := int64(len(b.mem))
+
+	if off < ml {
+		n = copy(p, b.mem[off:])
+	}
+
+	if n < len(p) && b.file != nil {
+		m, err := b.file.ReadAt(p[n:], off+int64(n)-ml)
+		n += m
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Size returns number of buffered bytes.
+func (b *SpillBuffer) Size() int64 {
+	return b.size
+}
+
+// Err returns error that stopped data capturing.
+func (b *SpillBuffer) Err() error {
+	return b.err
+}
+
+// Reset removes temporary file and resets buffer,
+// memory is kept, so buffer can be reused.
+func (b *SpillBuffer) Reset() error {
+	var err error
+
+	if b.file != nil {
+		err = b.file.Close()
+		if rerr := os.Remove(b.file.Name()); err == nil {
+			err = rerr
+		}
+		b.file = nil
+	}
+
+	b.mem = b.mem[:0]
+	b.size = 0
+	b.err = nil
+
+	return err
+}
@@ -0,0 +1,104 @@
+package httpdump
+
+import (
+	stdio "io"
+	"net/http"
+	"sync"
+
+	"github.com/hummerd/httpdump/io"
+)
+
+// WithLazyRequestCapture creates a new option that captures request body prefix
+// while handler reads it, instead of reading prefix before handler is called.
+// Request is dumped when handler closes request body or returns, so dumped body
+// contains only prefix of the part read by handler. Use RequestBodyRead to get
+// number of body bytes read by handler before request was dumped.
+//
+// Transport always reads request body prefix in advance.
+func WithLazyRequestCapture() Option {
+	return func(m *Middleware) {
+		m.lazyRequest = true
+	}
+}
+
+// RequestBodyRead returns number of request body bytes read by handler
+// before dumped request was dumped. It reports false if request
+// was not captured lazily, see WithLazyRequestCapture.
+func RequestBodyRead(r *http.Request) (int64, bool) {
+	st := stateFromRequest(r)
+	if st == nil || !st.lazy {
+		return 0, false
+	}
+
+	return st.bodyRead, true
+}
+
+// lazyRequest dumps request once when handler closes its body or returns.
+type lazyRequest struct {
+	m     *Middleware
+	r     *http.Request
+	cr    *io.PrefixReader
+	limit int
+	st    *requestState
+	read  int64
+	once  sync.Once
+
+	dr   *http.Request
+	body []byte
+	enc  *BodyEncoding
+}
+
+func (m *Middleware) newLazyRequest(r *http.Request, cr *io.PrefixReader, st *requestState, limit int) *lazyRequest {
+	lr := &lazyRequest{
+		m:     m,
+		r:     r,
+		cr:    cr,
+		limit: limit,
+		st:    st,
+	}
+
+	st.lazy = true
+	r.Body = &lazyBody{ReadCloser: r.Body, lr: lr}
+
+	return lr
+}
+
+func (lr *lazyRequest) dump() {
+	lr.once.Do(func() {
+		m := lr.m
+
+		lr.st.bodyRead = lr.read
+		lr.st.setRequestBody(requestBodySize(lr.r, lr.cr, lr.read, lr.limit))
+
+		if lr.cr != nil {
+			lr.body, lr.enc = m.decodedBody(lr.r.Header, lr.cr.Prefix(), lr.limit)
+			lr.body = m.dumpedBody(lr.r.Header, lr.body)
+		}
+
+		lr.dr = m.dumpedRequest(lr.r)
+
+		if m.dumpRequest != nil {
+			dr, body := lr.dr, lr.body
+			m.dispatch(func() {
+				m.dumpRequest(dr, body)
+			})
+		}
+	})
+}
+
+type lazyBody struct {
+	stdio.ReadCloser
+	lr *lazyRequest
+}
+
+func (lb *lazyBody) Read(p []byte) (int, error) {
+	n, err := lb.ReadCloser.Read(p)
+	lb.lr.read += int64(n)
+	return n, err
+}
+
+func (lb *lazyBody) Close() error {
+	err := lb.ReadCloser.Close()
+	lb.lr.dump()
+	return err
+}
@@ -0,0 +1,103 @@
+package httpdump_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_LazyRequestCapture(t *testing.T) {
+	reqBody := `{ "some": "json" }`
+
+	m, dump := newMiddleware(true, true, []httpdump.Option{httpdump.WithLazyRequestCapture()})
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		b := make([]byte, 6)
+		_, err := io.ReadFull(r.Body, b)
+		noerr(t, err)
+
+		if dump.reqDumped {
+			t.Errorf("Expected request not to be dumped before body is closed")
+		}
+
+		r.Body.Close()
+
+		if !dump.reqDumped || string(dump.reqBody) != reqBody[:6] {
+			t.Errorf("Expected request to be dumped on body close, got %v %q", dump.reqDumped, dump.reqBody)
+		}
+
+		n, _ := httpdump.RequestBodyRead(dump.req)
+		if n != 6 {
+			t.Errorf("Expected 6 bytes to be read by handler, got %d", n)
+		}
+	}))
+
+	src := &countingSource{Reader: strings.NewReader(reqBody)}
+	req := httptest.NewRequest(http.MethodPost, "/reject", src)
+	req.Header.Set("Content-Type", httpdump.MimeApplicationJSON)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if src.n != 0 {
+		t.Errorf("Expected body not to be read for rejected request, got %d bytes", src.n)
+	}
+
+	if !dump.reqDumped || len(dump.reqBody) != 0 || dump.resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected rejected request to be dumped after handler, got %v %q", dump.reqDumped, dump.reqBody)
+	}
+
+	if n, ok := httpdump.RequestBodyRead(dump.req); !ok || n != 0 {
+		t.Errorf("Expected no bytes to be read by handler, got %d %v", n, ok)
+	}
+
+	dump.reqDumped = false
+
+	req = httptest.NewRequest(http.MethodPost, "/partial", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", httpdump.MimeApplicationJSON)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if dump.resp.Request != dump.req {
+		t.Errorf("Expected the same dumped request in request and response dumps")
+	}
+
+	var bodyRead int64
+
+	m = httpdump.NewMiddleware(
+		func(rq *http.Request, body []byte) {
+			n, _ := httpdump.RequestBodyRead(rq)
+			bodyRead = n
+		},
+		nil,
+		httpdump.WithLazyRequestCapture(),
+	)
+	h = m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", httpdump.MimeApplicationJSON)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if bodyRead != int64(len(reqBody)) {
+		t.Errorf("Expected whole body to be read by handler, got %d", bodyRead)
+	}
+}
+
+type countingSource struct {
+	io.Reader
+	n int
+}
+
+func (cs *countingSource) Read(p []byte) (int, error) {
+	n, err := cs.Reader.Read(p)
+	cs.n += n
+	return n, err
+}
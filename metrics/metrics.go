@@ -0,0 +1,316 @@
+// Package metrics collects request metrics from exchanges dumped by httpdump
+// middleware and exposes them in Prometheus text exposition format.
+//
+// Only dumped exchanges are counted, requests skipped by filters, excluded paths
+// or sampling are counted by filtered counter of tracked middleware.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hummerd/httpdump"
+)
+
+// ContentType is a content type of Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	// DefaultDurationBuckets are default buckets of duration histogram in seconds.
+	DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// DefaultSizeBuckets are default buckets of body size histograms in bytes.
+	DefaultSizeBuckets = []float64{100, 1000, 10_000, 100_000, 1_000_000, 10_000_000}
+)
+
+// Option is a collector option that allows to override default collector settings.
+type Option func(*Collector)
+
+// WithNamespace creates a new option that sets prefix of metric names, default is "httpdump".
+func WithNamespace(namespace string) Option {
+	return func(c *Collector) {
+		c.namespace = namespace
+	}
+}
+
+// WithDurationBuckets creates a new option that sets upper bounds
+// of duration histogram buckets in seconds.
+func WithDurationBuckets(buckets ...float64) Option {
+	return func(c *Collector) {
+		c.durationBuckets = sortedBuckets(buckets)
+	}
+}
+
+// WithSizeBuckets creates a new option that sets upper bounds
+// of request and response size histogram buckets in bytes.
+func WithSizeBuckets(buckets ...float64) Option {
+	return func(c *Collector) {
+		c.sizeBuckets = sortedBuckets(buckets)
+	}
+}
+
+// WithRoute creates a new option that sets function returning route label of exchange.
+// By default it is httpdump.Exchange.Pattern or "unknown" if pattern is empty,
+// use it with routers other than http.ServeMux.
+func WithRoute(route func(ex *httpdump.Exchange) string) Option {
+	return func(c *Collector) {
+		c.route = route
+	}
+}
+
+// Collector counts dumped exchanges by method, route and status class.
+// Non-standard methods are counted as "other".
+// Safe to use from multiple goroutines.
+type Collector struct {
+	mu              sync.Mutex
+	series          map[labels]*series
+	tracked         []*httpdump.Middleware
+	namespace       string
+	durationBuckets []float64
+	sizeBuckets     []float64
+	route           func(ex *httpdump.Exchange) string
+}
+
+type labels struct {
+	method string
+	route  string
+	status string
+}
+
+type series struct {
+	requests     uint64
+	duration     histogram
+	requestSize  histogram
+	responseSize histogram
+}
+
+type histogram struct {
+	// counts are not cumulative, the last count is for +Inf bucket
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(buckets []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets)+1)
+	}
+
+	h.counts[sort.SearchFloat64s(buckets, v)]++
+	h.sum += v
+	h.count++
+}
+
+// New creates a new collector.
+func New(opts ...Option) *Collector {
+	c := &Collector{
+		series:          make(map[labels]*series),
+		namespace:       "httpdump",
+		durationBuckets: DefaultDurationBuckets,
+		sizeBuckets:     DefaultSizeBuckets,
+		route:           defaultRoute,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func defaultRoute(ex *httpdump.Exchange) string {
+	if ex.Pattern == "" {
+		return "unknown"
+	}
+
+	return ex.Pattern
+}
+
+// Track adds filtered and dropped dumps of middleware to exposed counters,
+// counters of all tracked middlewares are summed.
+func (c *Collector) Track(m *httpdump.Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tracked = append(c.tracked, m)
+}
+
+// DumpExchange is a httpdump.DumpExchangeFunc that counts exchange.
+// Body sizes of unknown length are not observed.
+func (c *Collector) DumpExchange(ex *httpdump.Exchange) {
+	l := labels{
+		method: methodLabel(ex.Request.Method),
+		route:  c.route(ex),
+		status: statusClass(ex.StatusCode),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.series[l]
+	if s == nil {
+		s = &series{}
+		c.series[l] = s
+	}
+
+	s.requests++
+	s.duration.observe(c.durationBuckets, ex.Duration.Seconds())
+
+	if ex.RequestBytes >= 0 {
+		s.requestSize.observe(c.sizeBuckets, float64(ex.RequestBytes))
+	}
+
+	if ex.ResponseBytes >= 0 {
+		s.responseSize.observe(c.sizeBuckets, float64(ex.ResponseBytes))
+	}
+}
+
+// ServeHTTP implements http.Handler, it responds with all metrics.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = c.Write(w)
+}
+
+// Write writes all metrics in Prometheus text exposition format.
+func (c *Collector) Write(w io.Writer) error {
+	c.mu.Lock()
+
+	keys := make([]labels, 0, len(c.series))
+	for l := range c.series {
+		keys = append(keys, l)
+	}
+
+	// sorted series make output stable
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		return a.status < b.status
+	})
+
+	// series are copied, so metrics are written without lock
+	ss := make([]series, len(keys))
+	for i, l := range keys {
+		ss[i] = c.series[l].clone()
+	}
+
+	var filtered, dropped uint64
+	for _, m := range c.tracked {
+		st := m.Stats()
+		filtered += st.Filtered
+		dropped += st.Dropped
+	}
+
+	c.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	c.header(bw, "requests_total", "counter", "Number of dumped requests.")
+	for i, l := range keys {
+		fmt.Fprintf(bw, "%s_requests_total{%s} %d\n", c.namespace, l.String(), ss[i].requests)
+	}
+
+	c.histograms(bw, "request_duration_seconds", "Duration of dumped requests in seconds.",
+		keys, ss, c.durationBuckets, func(s *series) *histogram { return &s.duration })
+	c.histograms(bw, "request_size_bytes", "Body size of dumped requests in bytes.",
+		keys, ss, c.sizeBuckets, func(s *series) *histogram { return &s.requestSize })
+	c.histograms(bw, "response_size_bytes", "Body size of dumped responses in bytes.",
+		keys, ss, c.sizeBuckets, func(s *series) *histogram { return &s.responseSize })
+
+	c.header(bw, "dumps_filtered_total", "counter", "Number of requests not dumped because of filters.")
+	fmt.Fprintf(bw, "%s_dumps_filtered_total %d\n", c.namespace, filtered)
+
+	c.header(bw, "dumps_dropped_total", "counter", "Number of dumps dropped because async queue was full.")
+	fmt.Fprintf(bw, "%s_dumps_dropped_total %d\n", c.namespace, dropped)
+
+	return bw.Flush()
+}
+
+func (c *Collector) histograms(
+	w io.Writer,
+	name, help string,
+	keys []labels,
+	ss []series,
+	buckets []float64,
+	get func(s *series) *histogram,
+) {
+	c.header(w, name, "histogram", help)
+
+	for i, l := range keys {
+		h := get(&ss[i])
+		if h.count == 0 {
+			continue
+		}
+
+		var cumulative uint64
+		for j, ub := range buckets {
+			cumulative += h.counts[j]
+			fmt.Fprintf(w, "%s_%s_bucket{%s,le=\"%s\"} %d\n", c.namespace, name, l, formatFloat(ub), cumulative)
+		}
+
+		fmt.Fprintf(w, "%s_%s_bucket{%s,le=\"+Inf\"} %d\n", c.namespace, name, l, h.count)
+		fmt.Fprintf(w, "%s_%s_sum{%s} %s\n", c.namespace, name, l, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_%s_count{%s} %d\n", c.namespace, name, l, h.count)
+	}
+}
+
+func (c *Collector) header(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s_%s %s\n# TYPE %s_%s %s\n", c.namespace, name, help, c.namespace, name, typ)
+}
+
+func (s *series) clone() series {
+	cs := *s
+	cs.duration.counts = append([]uint64(nil), s.duration.counts...)
+	cs.requestSize.counts = append([]uint64(nil), s.requestSize.counts...)
+	cs.responseSize.counts = append([]uint64(nil), s.responseSize.counts...)
+	return cs
+}
+
+func (l labels) String() string {
+	return `method="` + escape(l.method) + `",route="` + escape(l.route) + `",status="` + l.status + `"`
+}
+
+// methodLabel returns standard method as is and "other" for the rest,
+// so client supplied methods do not create unbounded number of series.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+
+	return "other"
+}
+
+func statusClass(status int) string {
+	if status < 100 || status > 999 {
+		return "unknown"
+	}
+
+	return strconv.Itoa(status/100) + "xx"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedBuckets(buckets []float64) []float64 {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return b
+}
@@ -0,0 +1,121 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/metrics"
+)
+
+func TestCollector(t *testing.T) {
+	c := metrics.New(metrics.WithDurationBuckets(1, 0.1), metrics.WithSizeBuckets(10))
+
+	m := httpdump.NewMiddleware(nil, nil,
+		httpdump.WithDumpExchange(c.DumpExchange),
+		httpdump.WithPathFilter(regexp.MustCompile("^/health")),
+	)
+	c.Track(m)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("item"))
+	})
+	mux.HandleFunc("POST /items", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("invalid item body"))
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+
+	h := m.Wrap(mux)
+
+	for _, r := range []struct {
+		method, target, body string
+	}{
+		{http.MethodGet, "/items/1", ""},
+		{http.MethodGet, "/items/2", ""},
+		{http.MethodPost, "/items", "{}"},
+		{http.MethodGet, "/health", ""},
+		{"FOO", "/items/1", ""},
+		{"BAR", "/items/2", ""},
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(r.method, r.target, strings.NewReader(r.body)))
+	}
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Unexpected content type %s", ct)
+	}
+
+	out := rec.Body.String()
+
+	for _, line := range []string{
+		"# TYPE httpdump_requests_total counter",
+		`httpdump_requests_total{method="GET",route="GET /items/{id}",status="2xx"} 2`,
+		`httpdump_requests_total{method="POST",route="POST /items",status="4xx"} 1`,
+		"# TYPE httpdump_request_duration_seconds histogram",
+		`httpdump_request_duration_seconds_bucket{method="GET",route="GET /items/{id}",status="2xx",le="0.1"} 2`,
+		`httpdump_request_duration_seconds_bucket{method="GET",route="GET /items/{id}",status="2xx",le="+Inf"} 2`,
+		`httpdump_request_duration_seconds_count{method="GET",route="GET /items/{id}",status="2xx"} 2`,
+		`httpdump_request_size_bytes_sum{method="POST",route="POST /items",status="4xx"} 2`,
+		`httpdump_response_size_bytes_bucket{method="POST",route="POST /items",status="4xx",le="10"} 0`,
+		`httpdump_response_size_bytes_bucket{method="POST",route="POST /items",status="4xx",le="+Inf"} 1`,
+		`httpdump_response_size_bytes_sum{method="GET",route="GET /items/{id}",status="2xx"} 8`,
+		`httpdump_requests_total{method="other",route="unknown",status="4xx"} 2`,
+		"httpdump_dumps_filtered_total 1",
+		"httpdump_dumps_dropped_total 0",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, out)
+		}
+	}
+
+	if strings.Contains(out, "/health") {
+		t.Errorf("Expected filtered request not to be counted")
+	}
+
+	if strings.Contains(out, "FOO") || strings.Contains(out, "BAR") {
+		t.Errorf("Expected non-standard methods not to be used as labels")
+	}
+}
+
+func TestCollector_Route(t *testing.T) {
+	c := metrics.New(
+		metrics.WithNamespace("api"),
+		metrics.WithRoute(func(ex *httpdump.Exchange) string {
+			return `a"b`
+		}),
+	)
+
+	c.DumpExchange(&httpdump.Exchange{
+		Request:       httptest.NewRequest(http.MethodGet, "/", nil),
+		StatusCode:    http.StatusOK,
+		Duration:      time.Second,
+		RequestBytes:  -1,
+		ResponseBytes: -1,
+	})
+
+	buf := &bytes.Buffer{}
+	if err := c.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+
+	if !strings.Contains(out, `api_requests_total{method="GET",route="a\"b",status="2xx"} 1`) {
+		t.Errorf("Unexpected metrics:\n%s", out)
+	}
+
+	if strings.Contains(out, "api_request_size_bytes_count") {
+		t.Errorf("Expected unknown request size not to be observed:\n%s", out)
+	}
+}
@@ -2,6 +2,7 @@ package httpdump
 
 import (
 	"bytes"
+	"context"
 	stdio "io"
 	"net/http"
 	"regexp"
@@ -20,6 +21,8 @@ const (
 const (
 	// Default limit for dumped body size (both for request and response).
 	DefaultBodySize = 1024
+	// Max limit for dumped body size, see WithLimitedBody and Middleware.UpdateSettings.
+	MaxBodySize = 64 << 20
 )
 
 const (
@@ -67,13 +70,11 @@ func FilterResponseBodyByContentType(contentTypes []string) ResponseFilterFunc {
 }
 
 // WithPathFilter creates a new option that excludes request and response by path.
+// Excluded paths can be changed at runtime, see Middleware.UpdateSettings.
 func WithPathFilter(regexps ...*regexp.Regexp) Option {
-	req := WithRequestPathFilter(regexps...)
-	res := WithResponsePathFilter(regexps...)
-
 	return func(m *Middleware) {
-		req(m)
-		res(m)
+		c := m.initialConfig()
+		c.excludedPaths = append(c.excludedPaths, regexps...)
 	}
 }
 
@@ -84,7 +85,8 @@ type Option func(*Middleware)
 // WithRequestFilters creates a new option that adds specified request filters.
 func WithRequestFilters(filters ...RequestFilterFunc) Option {
 	return func(m *Middleware) {
-		m.requestFilters = append(m.requestFilters, filters...)
+		c := m.initialConfig()
+		c.requestFilters = append(c.requestFilters, filters...)
 	}
 }
 
@@ -101,14 +103,16 @@ func WithRequestPathFilter(regexps ...*regexp.Regexp) Option {
 	}
 
 	return func(m *Middleware) {
-		m.requestFilters = append(m.requestFilters, f)
+		c := m.initialConfig()
+		c.requestFilters = append(c.requestFilters, f)
 	}
 }
 
 // WithResponseFilters creates a new option that adds specified response filters.
 func WithResponseFilters(filters ...ResponseFilterFunc) Option {
 	return func(m *Middleware) {
-		m.responseFilters = append(m.responseFilters, filters...)
+		c := m.initialConfig()
+		c.responseFilters = append(c.responseFilters, filters...)
 	}
 }
 
@@ -125,30 +129,41 @@ func WithResponsePathFilter(regexps ...*regexp.Regexp) Option {
 	}
 
 	return func(m *Middleware) {
-		m.responseFilters = append(m.responseFilters, f)
+		c := m.initialConfig()
+		c.responseFilters = append(c.responseFilters, f)
 	}
 }
 
-// WithLimitedBody creates a new option that sets limit for dumped body size.
+// WithLimitedBody creates a new option that sets limit for dumped body size,
+// limit must not be greater than MaxBodySize.
+// Limit can be changed at runtime, see Middleware.UpdateSettings.
 func WithLimitedBody(limit int) Option {
-	if limit <= 0 {
-		panic("httpdump: limit must be greater than 0")
+	if limit <= 0 || limit > MaxBodySize {
+		panic("httpdump: limit must be between 1 and MaxBodySize")
 	}
 
 	return func(m *Middleware) {
-		m.dumpedBodySize = limit
+		m.initialConfig().bodySize = limit
 	}
 }
 
 type Middleware struct {
-	enabled         *atomic.Bool
-	requestFilters  []RequestFilterFunc
-	dumpRequest     DumpRequestFunc
-	responseFilters []ResponseFilterFunc
-	dumpResponse    DumpResponseFunc
-	writerPool      *sync.Pool
-	readerPool      *sync.Pool
-	dumpedBodySize  int
+	config       atomic.Pointer[config]
+	configMu     sync.Mutex
+	dumpRequest  DumpRequestFunc
+	dumpResponse DumpResponseFunc
+	dumpExchange DumpExchangeFunc
+	redactor     *Redactor
+	panicMode    PanicMode
+	decompress   bool
+	decoders     map[string]Decoder
+	lazyRequest  bool
+	multipart    bool
+	async        *asyncQueue
+	writerPool   *sync.Pool
+	readerPool   *sync.Pool
+	spillPool    *sync.Pool
+	filtered     atomic.Uint64
 }
 
 // Creates http wrapper/middleware that dumps request and response.
@@ -172,45 +187,59 @@ func NewMiddleware(
 	dumpResponse DumpResponseFunc,
 	opts ...Option,
 ) *Middleware {
-	enabled := &atomic.Bool{}
-	enabled.Store(true)
-
 	m := &Middleware{
-		enabled:         enabled,
+		dumpRequest:  dumpRequest,
+		dumpResponse: dumpResponse,
+	}
+
+	m.config.Store(&config{
+		enabled:         true,
+		bodySize:        DefaultBodySize,
+		sampleRate:      1,
+		sampler:         newSampler(nil),
 		requestFilters:  []RequestFilterFunc{FilterRequestBodyByContentType(DefaultDumpedContentTypes)},
 		responseFilters: []ResponseFilterFunc{FilterResponseBodyByContentType(DefaultDumpedContentTypes)},
-		dumpRequest:     dumpRequest,
-		dumpResponse:    dumpResponse,
-		dumpedBodySize:  DefaultBodySize,
-	}
+	})
 
 	for _, opt := range opts {
 		opt(m)
 	}
 
+	if m.spillPool != nil && m.redactor != nil {
+		// complete bodies cannot be redacted, so they would leak redacted data
+		panic("httpdump: full body capture cannot be used with redaction")
+	}
+
+	// pooled readers and writers are resized if body limit is changed
 	m.writerPool = &sync.Pool{
 		New: func() any {
-			return newCachedWriter(nil, m.dumpedBodySize)
+			return newCachedWriter(nil, m.config.Load().bodySize)
 		},
 	}
 	m.readerPool = &sync.Pool{
 		New: func() any {
-			r, _ := io.NewPrefixReader(nil, m.dumpedBodySize)
+			r, _ := io.NewPrefixReader(nil, m.config.Load().bodySize)
 			return r
 		},
 	}
 
+	if m.async != nil {
+		m.async.start()
+	}
+
 	return m
 }
 
 // Enabled returns middleware enabled state. Safe to call from multiple goroutines.
 func (m *Middleware) Enabled() bool {
-	return m.enabled.Load()
+	return m.config.Load().enabled
 }
 
 // SetEnabled sets middleware enabled state. Safe to call from multiple goroutines.
 func (m *Middleware) SetEnabled(v bool) {
-	m.enabled.Store(v)
+	m.updateConfig(func(c *config) {
+		c.enabled = v
+	})
 }
 
 // Wrap wraps http.Handler with dump middleware.
@@ -221,66 +250,317 @@ func (m *Middleware) Wrap(h http.Handler) http.Handler {
 }
 
 func (m *Middleware) Handle(next http.Handler, w http.ResponseWriter, r *http.Request) {
-	if !m.enabled.Load() {
+	// the same settings are used for the whole request
+	cfg := m.config.Load()
+
+	start := time.Now()
+
+	// activations dump matching requests even if middleware is disabled
+	activation := cfg.activation(r, start)
+	if !cfg.enabled && activation == "" {
 		next.ServeHTTP(w, r)
 		return
 	}
 
-	start := time.Now()
+	st := newRequestState(start)
+	st.activation = activation
+	r = withRequestState(r, st)
 
-	dumpReq, dumpReqBody := m.needDumpRequest(r)
+	// complete bodies are kept until all dumps using them are done
+	sb := m.newSpilledBodies()
+	defer sb.release()
 
-	if dumpReq {
-		var reqBody []byte
+	dumpReq, dumpReqBody := m.needDumpRequest(r, cfg)
+
+	var (
+		reqBody []byte
+		reqEnc  *BodyEncoding
+		dr      *http.Request
+	)
 
+	var (
+		lr *lazyRequest
+		cr *io.PrefixReader
+	)
+
+	if dumpReq {
 		if dumpReqBody {
-			cr := m.readerPool.Get().(*io.PrefixReader)
+			cr = m.readerPool.Get().(*io.PrefixReader)
 			defer m.readerPool.Put(cr)
 
-			// it's ok to ignore error here
-			// further call to cr.Read() will return that error to caller
-			// and we expect it to be handled there
-			_ = cr.Reset(r.Body)
+			cr.Resize(cfg.bodySize)
+
+			if m.lazyRequest {
+				cr.ResetLazy(r.Body)
+			} else {
+				// it's ok to ignore error here
+				// further call to cr.Read() will return that error to caller
+				// and we expect it to be handled there
+				_ = cr.Reset(r.Body)
+			}
+
+			if sb != nil {
+				sb.request = sb.buffer()
+				cr.Tee(sb.request)
+			}
 
 			r.Body = cr
+		}
+
+		if m.lazyRequest {
+			lr = m.newLazyRequest(r, cr, st, cfg.bodySize)
+			// request is dumped even if handler panics
+			defer lr.dump()
+		} else {
+			if cr != nil {
+				reqBody, reqEnc = m.decodedBody(r.Header, cr.Prefix(), cfg.bodySize)
+				reqBody = m.dumpedBody(r.Header, reqBody)
+			}
+
+			st.setRequestBody(requestBodySize(r, cr, 0, cfg.bodySize))
+			dr = m.dumpedRequest(r)
 
-			reqBody = cr.Prefix()
+			if m.dumpRequest != nil {
+				m.dispatch(func() {
+					m.dumpRequest(dr, reqBody)
+				})
+			}
 		}
+	}
+
+	var rc *countingReader
 
-		m.dumpRequest(r, reqBody)
+	// not sampled request may be dumped with exchange if its response is kept
+	dumpEx := (dumpReq || st.unsampled) && m.dumpExchange != nil
+
+	if dumpEx && r.Body != nil {
+		rc = &countingReader{ReadCloser: r.Body}
+		r.Body = rc
 	}
 
 	var cw *cachedWriter
 
-	if m.dumpResponse != nil {
+	if m.needDumpResponse() || m.panicMode != 0 {
 		cw = m.writerPool.Get().(*cachedWriter)
 		defer m.writerPool.Put(cw)
 
-		cw.Reset(w, r, m.responseFilters...)
+		cw.PrefixWriter.Resize(cfg.bodySize)
+		cw.Reset(w, r, cfg)
+
+		if sb != nil {
+			sb.response = sb.buffer()
+			cw.PrefixWriter.Tee(sb.response)
+		}
+
+		w = wrapResponseWriter(cw)
+	}
+
+	p := m.serve(next, w, r)
+	if p != nil {
+		st.panic = p
+		abort := m.recovered(cw, p)
+		// panic again after response is dumped
+		defer m.repanic(p, abort)
+	}
+
+	if lr != nil {
+		lr.dump()
+		dr, reqBody, reqEnc = lr.dr, lr.body, lr.enc
+	}
+
+	var reqRead int64
+	if rc != nil {
+		reqRead = rc.n
+	} else if cr != nil {
+		reqRead = cr.BytesRead()
+	}
+
+	reqBytes, reqTruncated := requestBodySize(r, cr, reqRead, cfg.bodySize)
+	st.setRequestBody(reqBytes, reqTruncated)
+
+	if cw == nil {
+		m.countFiltered(dumpReq, false)
+		return
+	}
+
+	cw.EnsureFilterPassed()
+	m.countFiltered(dumpReq, cw.dumpResponse && m.needDumpResponse())
+
+	if !cw.dumpResponse {
+		return
+	}
+
+	duration := time.Since(start)
+	// body is encoded according to headers sent to client,
+	// trailers are set by handler after that
+	sentHeader := cw.SentHeader()
+	respHeader := m.redactedHeader(sentHeader)
+	respTrailer := m.redactedHeader(responseTrailers(cw.Header()))
+	st.informational = m.dumpedInformational(cw.informational)
+	respBody, respEnc := m.decodedBody(sentHeader, cw.Prefix(), cfg.bodySize)
+	respBody = m.dumpedBody(sentHeader, respBody)
+
+	if dr == nil {
+		dr = m.dumpedRequest(r)
+	}
 
-		w = cw
+	var fullReqBody, fullRespBody Body
+	if sb != nil {
+		fullReqBody = fullBody(sb.request)
+		if cw.dumpBody {
+			fullRespBody = fullBody(sb.response)
+		}
 	}
 
-	next.ServeHTTP(w, r)
+	st.responseTruncated.Store(cw.Truncated())
 
 	if m.dumpResponse != nil {
-		cw.EnsureFilterPassed()
+		resp := newDumpedResponse(dr, cw.Status(), respBody, respHeader, cw.BytesWritten())
+		resp.Trailer = respTrailer
+		if fullRespBody != nil {
+			resp.Body = stdio.NopCloser(stdio.NewSectionReader(fullRespBody, 0, fullRespBody.Size()))
+		}
 
-		if cw.dumpResponse {
-			respBody := cw.Prefix()
+		sb.acquire()
+		m.dispatchRelease(func() {
+			m.dumpResponse(resp, respBody, duration)
+		}, sb.release)
+	}
 
-			resp := newDumpedResponse(r, cw.Status(), respBody, cw.Header())
-			m.dumpResponse(resp, respBody, time.Since(start))
+	// not sampled request is dumped with exchange only if response filter kept it
+	if dumpEx && (dumpReq || keptUnsampled(r)) {
+		ex := &Exchange{
+			Request:           dr,
+			RequestBody:       reqBody,
+			Pattern:           requestPattern(r),
+			StatusCode:        cw.Status(),
+			ResponseHeader:    respHeader,
+			ResponseBody:      respBody,
+			RequestTrailer:    m.dumpedHeader(r.Trailer),
+			ResponseTrailer:   respTrailer,
+			Informational:     st.informational,
+			Start:             start,
+			Duration:          duration,
+			RequestBytes:      reqBytes,
+			RequestTruncated:  reqTruncated,
+			ResponseBytes:     cw.BytesWritten(),
+			ResponseTruncated: cw.Truncated(),
+			FullRequestBody:   fullReqBody,
+			FullResponseBody:  fullRespBody,
+			RequestEncoding:   reqEnc,
+			ResponseEncoding:  respEnc,
+			Panic:             p,
 		}
+
+		sb.acquire()
+		m.dispatchRelease(func() {
+			m.dumpExchange(ex)
+		}, sb.release)
 	}
 }
 
-func (m *Middleware) needDumpRequest(r *http.Request) (dump, body bool) {
-	if m.dumpRequest == nil {
+func (m *Middleware) needDumpRequest(r *http.Request, cfg *config) (dump, body bool) {
+	if m.dumpRequest == nil && m.dumpExchange == nil {
 		return false, false
 	}
 
-	return filterPassed(r, m.requestFilters)
+	return cfg.requestPassed(r)
+}
+
+func (m *Middleware) needDumpResponse() bool {
+	return m.dumpResponse != nil || m.dumpExchange != nil
+}
+
+// dumpedRequest returns request that is passed to dump functions,
+// it is a deep copy of request, so handler changes are not visible to dump functions.
+func (m *Middleware) dumpedRequest(r *http.Request) *http.Request {
+	ctx := r.Context()
+	if m.async != nil {
+		// request context is canceled when handler returns,
+		// but its values may be used by dump functions
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	r = r.Clone(ctx)
+	if m.async != nil {
+		r.Body = http.NoBody
+	}
+
+	if m.redactor != nil {
+		return m.redactor.Request(r)
+	}
+
+	return r
+}
+
+// dumpedResponse returns response that is passed to dump functions,
+// its headers are copied.
+func (m *Middleware) dumpedResponse(resp *http.Response, dr *http.Request) *http.Response {
+	rc := new(http.Response)
+	*rc = *resp
+	rc.Header = m.dumpedHeader(resp.Header)
+	rc.Trailer = m.dumpedHeader(resp.Trailer)
+	rc.Request = dr
+
+	if m.async != nil {
+		rc.Body = http.NoBody
+	}
+
+	return rc
+}
+
+// dumpedHeader returns copy of headers that is passed to dump functions.
+func (m *Middleware) dumpedHeader(h http.Header) http.Header {
+	if m.redactor != nil {
+		return m.redactor.Header(h)
+	}
+
+	return h.Clone()
+}
+
+// redactedHeader returns headers that are passed to dump functions,
+// headers must be already copied.
+func (m *Middleware) redactedHeader(h http.Header) http.Header {
+	if m.redactor != nil {
+		return m.redactor.Header(h)
+	}
+
+	return h
+}
+
+// dumpedInformational returns informational responses that are passed to dump functions,
+// their headers are already copied.
+func (m *Middleware) dumpedInformational(responses []InformationalResponse) []InformationalResponse {
+	for i := range responses {
+		responses[i].Header = m.redactedHeader(responses[i].Header)
+	}
+
+	return responses
+}
+
+// dumpedBody returns body that is passed to dump functions.
+func (m *Middleware) dumpedBody(h http.Header, body []byte) []byte {
+	if m.multipart && len(body) > 0 {
+		if boundary, ok := multipartBoundary(h); ok {
+			// summary is a new slice, so it is not cloned
+			body = m.multipartSummary(boundary, body)
+			if m.redactor != nil {
+				return m.redactor.Body(h, body)
+			}
+
+			return body
+		}
+	}
+
+	if m.redactor != nil {
+		return m.redactor.Body(h, body)
+	}
+
+	if m.async != nil {
+		return bytes.Clone(body)
+	}
+
+	return body
 }
 
 func filterPassed(r *http.Request, filters []RequestFilterFunc) (dump, body bool) {
@@ -300,21 +580,45 @@ func filterPassed(r *http.Request, filters []RequestFilterFunc) (dump, body bool
 	return true, b
 }
 
+func filterResponsePassed(
+	r *http.Request,
+	headers http.Header,
+	status int,
+	filters []ResponseFilterFunc,
+) (dump, body bool) {
+	b := true
+
+	for _, f := range filters {
+		dump, body := f(r, headers, status)
+		if !dump {
+			return false, false
+		}
+
+		if !body {
+			b = false
+		}
+	}
+
+	return true, b
+}
+
 func newDumpedResponse(
 	r *http.Request,
 	status int,
 	body []byte,
 	headers http.Header,
+	contentLength int64,
 ) *http.Response {
 	return &http.Response{
-		Proto:      r.Proto,
-		ProtoMajor: r.ProtoMajor,
-		ProtoMinor: r.ProtoMinor,
-		Request:    r,
-		Status:     http.StatusText(status),
-		StatusCode: status,
-		Body:       stdio.NopCloser(bytes.NewReader(body)),
-		Header:     headers,
+		Proto:         r.Proto,
+		ProtoMajor:    r.ProtoMajor,
+		ProtoMinor:    r.ProtoMinor,
+		Request:       r,
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Body:          stdio.NopCloser(bytes.NewReader(body)),
+		ContentLength: contentLength,
+		Header:        headers,
 	}
 }
 
@@ -341,9 +645,14 @@ type cachedWriter struct {
 	statusCode   int
 	request      *http.Request
 	written      bool
+	bytesWritten int64
 	dumpBody     bool
 	dumpResponse bool
-	filters      []ResponseFilterFunc
+	config       *config
+	// sentHeader is a copy of headers made when they are sent
+	sentHeader http.Header
+	// informational responses with headers copied when they are sent
+	informational []InformationalResponse
 }
 
 func (cw *cachedWriter) Status() int {
@@ -356,22 +665,59 @@ func (cw *cachedWriter) Status() int {
 	return sc
 }
 
-func (cw *cachedWriter) Reset(w http.ResponseWriter, r *http.Request, filters ...ResponseFilterFunc) {
+func (cw *cachedWriter) Reset(w http.ResponseWriter, r *http.Request, cfg *config) {
 	cw.PrefixWriter.Reset(w)
 
 	cw.w = w
 	cw.statusCode = 0
 	cw.request = r
 	cw.written = false
+	cw.bytesWritten = 0
 	cw.dumpBody = false
 	cw.dumpResponse = false
-	cw.filters = filters
+	cw.config = cfg
+	cw.sentHeader = nil
+	cw.informational = nil
+}
+
+// BytesWritten returns total number of response body bytes written,
+// including bytes that are not dumped.
+func (cw *cachedWriter) BytesWritten() int64 {
+	return cw.bytesWritten
+}
+
+// Truncated reports whether response body is dumped and
+// dumped prefix is shorter than written body.
+func (cw *cachedWriter) Truncated() bool {
+	return cw.dumpBody && cw.PrefixWriter.Truncated()
+}
+
+// HeaderWritten reports whether response headers were sent to client.
+func (cw *cachedWriter) HeaderWritten() bool {
+	return cw.written || cw.statusCode != 0
 }
 
 func (cw *cachedWriter) Header() http.Header {
 	return cw.w.Header()
 }
 
+// SentHeader returns copy of headers made when they were sent to client,
+// changes made by handler after that are not included.
+func (cw *cachedWriter) SentHeader() http.Header {
+	if cw.sentHeader == nil {
+		cw.snapshotHeader()
+	}
+
+	return cw.sentHeader
+}
+
+func (cw *cachedWriter) snapshotHeader() {
+	cw.sentHeader = cw.w.Header().Clone()
+	if cw.sentHeader == nil {
+		cw.sentHeader = make(http.Header)
+	}
+}
+
 func (cw *cachedWriter) EnsureFilterPassed() {
 	if cw.written {
 		return
@@ -381,9 +727,13 @@ func (cw *cachedWriter) EnsureFilterPassed() {
 		cw.statusCode = http.StatusOK
 	}
 
+	if cw.sentHeader == nil {
+		cw.snapshotHeader()
+	}
+
 	d, b := cw.filtered(
 		cw.request,
-		cw.w.Header(),
+		cw.sentHeader,
 		cw.statusCode,
 	)
 
@@ -396,10 +746,13 @@ func (cw *cachedWriter) Write(data []byte) (int, error) {
 	cw.EnsureFilterPassed()
 
 	if !cw.dumpBody {
-		return cw.w.Write(data)
+		n, err := cw.w.Write(data)
+		cw.bytesWritten += int64(n)
+		return n, err
 	}
 
 	n, err := cw.PrefixWriter.Write(data)
+	cw.bytesWritten += int64(n)
 	if err != nil {
 		return 0, err
 	}
@@ -407,26 +760,19 @@ func (cw *cachedWriter) Write(data []byte) (int, error) {
 }
 
 func (cw *cachedWriter) WriteHeader(statusCode int) {
-	if cw.statusCode == 0 {
+	if cw.statusCode == 0 && !cw.written && isInformational(statusCode) {
+		cw.informational = append(cw.informational, InformationalResponse{
+			StatusCode: statusCode,
+			Header:     cw.w.Header().Clone(),
+		})
+	} else if cw.statusCode == 0 {
 		cw.statusCode = statusCode
+		cw.snapshotHeader()
 	}
 
 	cw.w.WriteHeader(statusCode)
 }
 
 func (cw *cachedWriter) filtered(r *http.Request, headers http.Header, status int) (bool, bool) {
-	b := true
-
-	for _, f := range cw.filters {
-		dump, body := f(r, headers, status)
-		if !dump {
-			return false, false
-		}
-
-		if !body {
-			b = false
-		}
-	}
-
-	return true, b
+	return cw.config.responsePassed(r, headers, status)
 }
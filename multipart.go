@@ -0,0 +1,172 @@
+package httpdump
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	stdio "io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	MimeMultipartForm = "multipart/form-data"
+)
+
+var errNotMultipart = errors.New("httpdump: body is not multipart")
+
+// MultipartPart is a part of captured multipart body prefix.
+type MultipartPart struct {
+	Header textproto.MIMEHeader
+	// Name is a form field name and FileName is a name of uploaded file,
+	// both are taken from Content-Disposition header.
+	Name     string
+	FileName string
+	// Value is a value of text field, it is nil for files.
+	Value []byte
+	// Size is a number of captured part content bytes.
+	Size int
+	// SHA256 is a hex encoded SHA-256 of captured file content, it is empty for text fields.
+	SHA256 string
+	// Truncated reports whether part content is captured partially.
+	Truncated bool
+}
+
+// WithMultipartSummary creates a new option that dumps multipart bodies as summary:
+// a multipart document with the same part headers, where text field values
+// are kept and file contents are replaced by their size and SHA-256.
+// Partially captured parts are marked as truncated. Summary is used for both
+// requests and responses, see ParseMultipart for structured parts.
+//
+// Option adds MimeMultipartForm to default dumped content types,
+// custom content type filters must allow it too.
+func WithMultipartSummary() Option {
+	return func(m *Middleware) {
+		m.multipart = true
+
+		types := append(slices.Clone(DefaultDumpedContentTypes), MimeMultipartForm)
+
+		// default content type filters are the first ones until middleware is created
+		c := m.initialConfig()
+		c.requestFilters[0] = FilterRequestBodyByContentType(types)
+		c.responseFilters[0] = FilterResponseBodyByContentType(types)
+	}
+}
+
+// ParseMultipart parses captured prefix of multipart body. Parsing stops at the first
+// partially captured part, truncated reports whether body was captured partially.
+func ParseMultipart(h http.Header, body []byte) (parts []MultipartPart, truncated bool, err error) {
+	boundary, ok := multipartBoundary(h)
+	if !ok {
+		return nil, false, errNotMultipart
+	}
+
+	parts, truncated = parseMultipart(boundary, body)
+	return parts, truncated, nil
+}
+
+func parseMultipart(boundary string, body []byte) (parts []MultipartPart, truncated bool) {
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+
+	for {
+		p, err := mr.NextRawPart()
+		if errors.Is(err, stdio.EOF) {
+			return parts, false
+		}
+
+		if err != nil {
+			// part headers or boundary are not captured completely
+			return parts, true
+		}
+
+		content, err := stdio.ReadAll(p)
+
+		part := MultipartPart{
+			Header:    p.Header,
+			Name:      p.FormName(),
+			FileName:  p.FileName(),
+			Size:      len(content),
+			Truncated: err != nil,
+		}
+
+		if part.FileName == "" {
+			part.Value = content
+		} else {
+			sum := sha256.Sum256(content)
+			part.SHA256 = hex.EncodeToString(sum[:])
+		}
+
+		parts = append(parts, part)
+
+		if part.Truncated {
+			return parts, true
+		}
+	}
+}
+
+func multipartBoundary(h http.Header) (string, bool) {
+	mt, params, err := mime.ParseMediaType(h.Get(HeaderContentType))
+	if err != nil || !strings.HasPrefix(mt, "multipart/") || params["boundary"] == "" {
+		return "", false
+	}
+
+	return params["boundary"], true
+}
+
+// multipartSummary returns summary of multipart body, see WithMultipartSummary.
+func (m *Middleware) multipartSummary(boundary string, body []byte) []byte {
+	parts, truncated := parseMultipart(boundary, body)
+
+	buf := &bytes.Buffer{}
+
+	for _, p := range parts {
+		fmt.Fprintf(buf, "--%s\r\n", boundary)
+
+		keys := make([]string, 0, len(p.Header))
+		for k := range p.Header {
+			keys = append(keys, k)
+		}
+		// sorted headers make dumps stable
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			for _, v := range p.Header[k] {
+				fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+			}
+		}
+
+		buf.WriteString("\r\n")
+
+		switch {
+		case p.FileName != "" && p.Truncated:
+			fmt.Fprintf(buf, "[file: %d bytes captured, truncated, sha256 of captured bytes: %s]", p.Size, p.SHA256)
+		case p.FileName != "":
+			fmt.Fprintf(buf, "[file: %d bytes, sha256: %s]", p.Size, p.SHA256)
+		case m.redactor != nil && m.redactor.formField(p.Name):
+			buf.WriteString(m.redactor.mask)
+		default:
+			buf.Write(p.Value)
+		}
+
+		if p.Truncated && p.FileName == "" {
+			buf.WriteString("[truncated]")
+		}
+
+		buf.WriteString("\r\n")
+	}
+
+	if truncated {
+		buf.WriteString("[truncated]\r\n")
+	} else {
+		fmt.Fprintf(buf, "--%s--\r\n", boundary)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,140 @@
+package httpdump_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_MultipartSummary(t *testing.T) {
+	file := bytes.Repeat([]byte{0xff, 0x00}, 100)
+	body, contentType := multipartBody(t, file)
+
+	sum := sha256.Sum256(file)
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "complete",
+			limit: 4096,
+			want: []string{
+				"Content-Disposition: form-data; name=\"title\"\r\n\r\nreport\r\n",
+				"Content-Disposition: form-data; name=\"token\"\r\n\r\n[REDACTED]\r\n",
+				"Content-Disposition: form-data; name=\"file\"; filename=\"data.bin\"\r\n" +
+					"Content-Type: application/octet-stream\r\n\r\n" +
+					fmt.Sprintf("[file: 200 bytes, sha256: %s]\r\n", hex.EncodeToString(sum[:])),
+				"--b0undary--\r\n",
+			},
+		},
+		{
+			name:  "truncated",
+			limit: bytes.Index(body, file) + 50,
+			want: []string{
+				"report\r\n",
+				"[file: 50 bytes captured, truncated, sha256 of captured bytes: ",
+				"]\r\n[truncated]\r\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var respBody []byte
+
+			m, dump := newMiddleware(true, false, []httpdump.Option{
+				httpdump.WithMultipartSummary(),
+				httpdump.WithLimitedBody(tt.limit),
+				httpdump.WithRedaction(httpdump.RedactFormFields("token")),
+				httpdump.WithDumpExchange(func(ex *httpdump.Exchange) {
+					respBody = ex.ResponseBody
+				}),
+			})
+
+			h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(io.Discard, r.Body)
+
+				w.Header().Set(httpdump.HeaderContentType, contentType)
+				_, _ = w.Write(body)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(body))
+			req.Header.Set(httpdump.HeaderContentType, contentType)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if !bytes.Equal(rec.Body.Bytes(), body) {
+				t.Errorf("Expected response body not to be changed")
+			}
+
+			for _, dumped := range []string{string(dump.reqBody), string(respBody)} {
+				for _, w := range tt.want {
+					if !strings.Contains(dumped, w) {
+						t.Errorf("Expected summary to contain %q, got:\n%s", w, dumped)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseMultipart(t *testing.T) {
+	file := []byte("file content")
+	body, contentType := multipartBody(t, file)
+
+	h := http.Header{httpdump.HeaderContentType: {contentType}}
+
+	// cut closing boundary and last 4 bytes of file
+	parts, truncated, err := httpdump.ParseMultipart(h, body[:len(body)-20])
+	noerr(t, err)
+
+	if !truncated || len(parts) != 3 {
+		t.Fatalf("Expected 3 parts of truncated body, got %d, %v", len(parts), truncated)
+	}
+
+	if parts[0].Name != "title" || string(parts[0].Value) != "report" || parts[0].Truncated {
+		t.Errorf("Unexpected text part %+v", parts[0])
+	}
+
+	if parts[2].Name != "file" || parts[2].FileName != "data.bin" || parts[2].Value != nil ||
+		parts[2].Size != len(file)-4 || !parts[2].Truncated || parts[2].SHA256 == "" {
+		t.Errorf("Unexpected file part %+v", parts[2])
+	}
+
+	_, _, err = httpdump.ParseMultipart(http.Header{httpdump.HeaderContentType: {httpdump.MimeTextPlain}}, body)
+	if err == nil {
+		t.Errorf("Expected error for not multipart body")
+	}
+}
+
+func multipartBody(t *testing.T, file []byte) ([]byte, string) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+
+	mw := multipart.NewWriter(buf)
+	noerr(t, mw.SetBoundary("b0undary"))
+	noerr(t, mw.WriteField("title", "report"))
+	noerr(t, mw.WriteField("token", "secret"))
+
+	fw, err := mw.CreateFormFile("file", "data.bin")
+	noerr(t, err)
+
+	_, err = fw.Write(file)
+	noerr(t, err)
+	noerr(t, mw.Close())
+
+	return buf.Bytes(), mw.FormDataContentType()
+}
@@ -0,0 +1,225 @@
+// Package oteldump records requests and responses dumped by httpdump middleware
+// to OpenTelemetry span found in request context.
+//
+// Span must not be ended before dump functions are called, so middleware should
+// be wrapped by tracing middleware and should not dump asynchronously.
+//
+// The package is a separate module to keep OpenTelemetry out of httpdump dependencies.
+package oteldump
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hummerd/httpdump"
+)
+
+const (
+	// RequestEventName is a name of span event with dumped request.
+	RequestEventName = "http.request.dump"
+	// ResponseEventName is a name of span event with dumped response.
+	ResponseEventName = "http.response.dump"
+
+	// RequestBodyKey and ResponseBodyKey are keys of dumped bodies and
+	// DurationKey is a key of response duration in seconds,
+	// semantic conventions do not define them.
+	RequestBodyKey  = attribute.Key("http.request.body")
+	ResponseBodyKey = attribute.Key("http.response.body")
+	DurationKey     = attribute.Key("http.response.duration")
+)
+
+// Mode defines how dumps are recorded to span.
+type Mode int
+
+const (
+	// Events records request and response as span events.
+	Events Mode = iota
+	// Attributes records request and response as span attributes.
+	Attributes
+)
+
+// Option is a recorder option that allows to override default recorder settings.
+type Option func(*Recorder)
+
+// WithMode creates a new option that sets how dumps are recorded, default is Events.
+func WithMode(mode Mode) Option {
+	return func(rc *Recorder) {
+		rc.mode = mode
+	}
+}
+
+// WithHeaders creates a new option that records only specified headers.
+// By default all headers are recorded, without names no headers are recorded.
+func WithHeaders(names ...string) Option {
+	return func(rc *Recorder) {
+		rc.headers = make([]string, 0, len(names))
+		for _, n := range names {
+			rc.headers = append(rc.headers, http.CanonicalHeaderKey(n))
+		}
+	}
+}
+
+// WithBodyLimit creates a new option that limits size of recorded bodies,
+// default limit is httpdump.DefaultBodySize.
+func WithBodyLimit(limit int) Option {
+	return func(rc *Recorder) {
+		rc.bodyLimit = limit
+	}
+}
+
+// WithErrorStatus creates a new option that sets minimal response status code
+// that sets span status to error, default is 500.
+func WithErrorStatus(status int) Option {
+	return func(rc *Recorder) {
+		rc.errorStatus = status
+	}
+}
+
+// Recorder records dumped requests and responses to spans.
+type Recorder struct {
+	mode        Mode
+	headers     []string
+	bodyLimit   int
+	errorStatus int
+}
+
+// New creates a new recorder.
+func New(opts ...Option) *Recorder {
+	rc := &Recorder{
+		bodyLimit:   httpdump.DefaultBodySize,
+		errorStatus: http.StatusInternalServerError,
+	}
+
+	for _, opt := range opts {
+		opt(rc)
+	}
+
+	return rc
+}
+
+// Funcs creates a new recorder and returns its dump functions,
+// they can be passed directly to httpdump.NewMiddleware.
+func Funcs(opts ...Option) (httpdump.DumpRequestFunc, httpdump.DumpResponseFunc) {
+	rc := New(opts...)
+	return rc.DumpRequest, rc.DumpResponse
+}
+
+// DumpRequest is a httpdump.DumpRequestFunc that records request to span.
+func (rc *Recorder) DumpRequest(rq *http.Request, body []byte) {
+	span := trace.SpanFromContext(rq.Context())
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := make([]attribute.KeyValue, 0, 6+len(rq.Header))
+	attrs = append(attrs, semconv.HTTPRequestMethodKey.String(rq.Method))
+	attrs = appendURL(attrs, rq)
+
+	if rq.ContentLength >= 0 {
+		attrs = append(attrs, semconv.HTTPRequestBodySize(int(rq.ContentLength)))
+	}
+
+	attrs = rc.appendHeaders(attrs, "http.request.header.", rq.Header)
+	attrs = append(attrs, RequestBodyKey.String(rc.body(body)))
+
+	rc.record(span, RequestEventName, attrs)
+}
+
+// appendURL appends url.full for client requests, server requests have
+// only path and query in URL, so url.scheme, url.path and url.query are appended.
+func appendURL(attrs []attribute.KeyValue, rq *http.Request) []attribute.KeyValue {
+	if rq.URL.IsAbs() {
+		return append(attrs, semconv.URLFull(rq.URL.String()))
+	}
+
+	scheme := "http"
+	if rq.TLS != nil {
+		scheme = "https"
+	}
+
+	attrs = append(attrs, semconv.URLScheme(scheme), semconv.URLPath(rq.URL.Path))
+	if rq.URL.RawQuery != "" {
+		attrs = append(attrs, semconv.URLQuery(rq.URL.RawQuery))
+	}
+
+	return attrs
+}
+
+// DumpResponse is a httpdump.DumpResponseFunc that records response to span
+// and sets span status from response status code.
+func (rc *Recorder) DumpResponse(rp *http.Response, body []byte, duration time.Duration) {
+	span := trace.SpanFromContext(rp.Request.Context())
+	if !span.IsRecording() {
+		return
+	}
+
+	if rp.StatusCode >= rc.errorStatus {
+		span.SetStatus(codes.Error, http.StatusText(rp.StatusCode))
+	}
+
+	status := semconv.HTTPResponseStatusCode(rp.StatusCode)
+	span.SetAttributes(status)
+
+	attrs := make([]attribute.KeyValue, 0, 4+len(rp.Header))
+	attrs = append(attrs, status)
+
+	if rp.ContentLength >= 0 {
+		attrs = append(attrs, semconv.HTTPResponseBodySize(int(rp.ContentLength)))
+	}
+
+	attrs = rc.appendHeaders(attrs, "http.response.header.", rp.Header)
+	attrs = append(attrs,
+		ResponseBodyKey.String(rc.body(body)),
+		DurationKey.Float64(duration.Seconds()))
+
+	rc.record(span, ResponseEventName, attrs)
+}
+
+func (rc *Recorder) record(span trace.Span, event string, attrs []attribute.KeyValue) {
+	if rc.mode == Attributes {
+		span.SetAttributes(attrs...)
+		return
+	}
+
+	span.AddEvent(event, trace.WithAttributes(attrs...))
+}
+
+// appendHeaders appends headers with semantic convention keys,
+// e.g. http.request.header.content-type.
+func (rc *Recorder) appendHeaders(attrs []attribute.KeyValue, prefix string, h http.Header) []attribute.KeyValue {
+	if rc.headers == nil {
+		for k, vs := range h {
+			attrs = append(attrs, attribute.StringSlice(prefix+strings.ToLower(k), vs))
+		}
+
+		return attrs
+	}
+
+	for _, k := range rc.headers {
+		if vs, ok := h[k]; ok {
+			attrs = append(attrs, attribute.StringSlice(prefix+strings.ToLower(k), vs))
+		}
+	}
+
+	return attrs
+}
+
+// body returns body as valid UTF-8 string within body limit.
+func (rc *Recorder) body(body []byte) string {
+	if len(body) > rc.bodyLimit {
+		body = body[:rc.bodyLimit]
+	}
+
+	if utf8.Valid(body) {
+		return string(body)
+	}
+
+	return strings.ToValidUTF8(string(body), string(utf8.RuneError))
+}
@@ -0,0 +1,182 @@
+package oteldump_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/oteldump"
+)
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []oteldump.Option
+		status int
+		check  func(t *testing.T, span tracetest.SpanStub)
+	}{
+		{
+			name:   "events",
+			opts:   []oteldump.Option{oteldump.WithBodyLimit(4), oteldump.WithHeaders("X-Request")},
+			status: http.StatusBadGateway,
+			check: func(t *testing.T, span tracetest.SpanStub) {
+				if span.Status.Code != codes.Error {
+					t.Errorf("Expected error span status, got %v", span.Status)
+				}
+
+				if len(span.Events) != 2 || span.Events[0].Name != oteldump.RequestEventName ||
+					span.Events[1].Name != oteldump.ResponseEventName {
+					t.Fatalf("Unexpected events %+v", span.Events)
+				}
+
+				req := attrs(span.Events[0].Attributes)
+				expectAttr(t, req, "http.request.method", "POST")
+				expectAttr(t, req, "url.full", "")
+				expectAttr(t, req, "url.scheme", "http")
+				expectAttr(t, req, "url.path", "/items")
+				expectAttr(t, req, "url.query", "id=1")
+				expectAttr(t, req, "http.request.body", "requ")
+				expectAttr(t, req, "http.request.header.x-request", `["a"]`)
+				expectAttr(t, req, "http.request.header.content-type", "")
+
+				resp := attrs(span.Events[1].Attributes)
+				expectAttr(t, resp, "http.response.status_code", "502")
+				expectAttr(t, resp, "http.response.body", "resp")
+				expectAttr(t, resp, "http.response.header.x-response", "")
+
+				expectAttr(t, attrs(span.Attributes), "http.response.status_code", "502")
+			},
+		},
+		{
+			name:   "attributes",
+			opts:   []oteldump.Option{oteldump.WithMode(oteldump.Attributes)},
+			status: http.StatusNotFound,
+			check: func(t *testing.T, span tracetest.SpanStub) {
+				if span.Status.Code != codes.Unset {
+					t.Errorf("Expected unset span status, got %v", span.Status)
+				}
+
+				if len(span.Events) != 0 {
+					t.Errorf("Unexpected events %+v", span.Events)
+				}
+
+				a := attrs(span.Attributes)
+				expectAttr(t, a, "http.request.body", "request body")
+				expectAttr(t, a, "http.response.body", "response body")
+				expectAttr(t, a, "http.response.status_code", "404")
+				expectAttr(t, a, "http.response.header.x-response", `["b"]`)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			tracer := tp.Tracer("test")
+
+			dreq, dresp := oteldump.Funcs(tt.opts...)
+			m := httpdump.NewMiddleware(dreq, dresp)
+
+			h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", httpdump.MimeTextPlain)
+				w.Header().Set("X-Response", "b")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte("response body"))
+			}))
+
+			ctx, span := tracer.Start(context.Background(), "request")
+
+			req := httptest.NewRequest(http.MethodPost, "/items?id=1", strings.NewReader("request body")).WithContext(ctx)
+			req.Header.Set("Content-Type", httpdump.MimeTextPlain)
+			req.Header.Set("X-Request", "a")
+
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			span.End()
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("Expected 1 span, got %d", len(spans))
+			}
+
+			tt.check(t, spans[0])
+		})
+	}
+}
+
+func TestRecorder_ClientRequest(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	dreq, dresp := oteldump.Funcs()
+	c := &http.Client{Transport: httpdump.NewTransport(s.Client().Transport, dreq, dresp)}
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/items?id=1", http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	span.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || len(spans[0].Events) == 0 {
+		t.Fatalf("Expected request event to be recorded, got %+v", spans)
+	}
+
+	a := attrs(spans[0].Events[0].Attributes)
+	expectAttr(t, a, "url.full", s.URL+"/items?id=1")
+	expectAttr(t, a, "url.path", "")
+}
+
+func TestRecorder_NoSpan(t *testing.T) {
+	dreq, dresp := oteldump.Funcs()
+	m := httpdump.NewMiddleware(dreq, dresp)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// request without span must not fail
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func attrs(kvs []attribute.KeyValue) map[string]string {
+	m := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		m[string(kv.Key)] = kv.Value.Emit()
+	}
+
+	return m
+}
+
+func expectAttr(t *testing.T, attrs map[string]string, key, value string) {
+	t.Helper()
+
+	v, ok := attrs[key]
+	if value == "" {
+		if ok {
+			t.Errorf("Expected no attribute %s, got %q", key, v)
+		}
+		return
+	}
+
+	if v != value {
+		t.Errorf("Expected attribute %s to be %q, got %q", key, value, v)
+	}
+}
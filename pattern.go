@@ -0,0 +1,10 @@
+//go:build go1.23
+
+package httpdump
+
+import "net/http"
+
+// requestPattern returns pattern set by http.ServeMux.
+func requestPattern(r *http.Request) string {
+	return r.Pattern
+}
@@ -0,0 +1,10 @@
+//go:build !go1.23
+
+package httpdump
+
+import "net/http"
+
+// requestPattern returns empty string, http.Request has no pattern before Go 1.23.
+func requestPattern(_ *http.Request) string {
+	return ""
+}
@@ -0,0 +1,116 @@
+package httpdump
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicMode defines how middleware handles recovered panic after response is dumped.
+type PanicMode int
+
+const (
+	// PanicRepanic panics again with recovered value,
+	// so panic can be handled by outer middleware or http.Server.
+	PanicRepanic PanicMode = iota + 1
+	// PanicRespondError responds with 500 Internal Server Error and does not panic again.
+	// If headers were already written, response cannot be completed, so it panics
+	// with http.ErrAbortHandler to abort response instead.
+	PanicRespondError
+)
+
+// PanicInfo is a panic recovered from handler.
+type PanicInfo struct {
+	Value any
+	// Stack is a stack trace of the panicked goroutine,
+	// it is empty for http.ErrAbortHandler.
+	Stack []byte
+}
+
+// WithPanicRecovery creates a new option that recovers handler panics,
+// so response is dumped even if handler panics. Dumped status is 500 unless
+// headers were already written, panic value and stack are set to Exchange.Panic
+// and are returned by RecoveredPanic for dumped response.
+//
+// http.ErrAbortHandler is always re-panicked regardless of mode,
+// since handler wants the response to be aborted.
+func WithPanicRecovery(mode PanicMode) Option {
+	if mode != PanicRepanic && mode != PanicRespondError {
+		panic("httpdump: unknown panic mode")
+	}
+
+	return func(m *Middleware) {
+		m.panicMode = mode
+	}
+}
+
+// RecoveredPanic returns panic recovered from handler of dumped response,
+// it returns nil if handler did not panic, see WithPanicRecovery.
+func RecoveredPanic(resp *http.Response) *PanicInfo {
+	if resp.Request == nil {
+		return nil
+	}
+
+	st := stateFromRequest(resp.Request)
+	if st == nil {
+		return nil
+	}
+
+	return st.panic
+}
+
+// serve calls handler and recovers panic if panic recovery is enabled.
+func (m *Middleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request) (p *PanicInfo) {
+	if m.panicMode == 0 {
+		next.ServeHTTP(w, r)
+		return nil
+	}
+
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+
+		p = &PanicInfo{Value: v}
+		if v != http.ErrAbortHandler {
+			p.Stack = debug.Stack()
+		}
+	}()
+
+	next.ServeHTTP(w, r)
+
+	return nil
+}
+
+// recovered sets the status that will be sent to client after panic,
+// it reports whether response was partially written and must be aborted.
+func (m *Middleware) recovered(cw *cachedWriter, p *PanicInfo) (abort bool) {
+	if cw.HeaderWritten() {
+		return true
+	}
+
+	if m.panicMode == PanicRespondError && p.Value != http.ErrAbortHandler {
+		// write through cachedWriter, so error response is dumped
+		http.Error(
+			cw,
+			http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+		return false
+	}
+
+	// response is not written yet, outer handler or server is expected
+	// to respond with 500 or abort connection
+	cw.statusCode = http.StatusInternalServerError
+	return false
+}
+
+func (m *Middleware) repanic(p *PanicInfo, abort bool) {
+	if m.panicMode == PanicRepanic || p.Value == http.ErrAbortHandler {
+		panic(p.Value)
+	}
+
+	if abort {
+		// partially written response must not look complete to client
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,166 @@
+package httpdump_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_PanicRepanic(t *testing.T) {
+	m, dump, ex := newPanicMiddleware(httpdump.PanicRepanic)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	v := servePanic(h, rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if v != "boom" {
+		t.Errorf("Expected panic value to be re-panicked, got %v", v)
+	}
+
+	if !dump.respDumped || dump.resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected response to be dumped with status 500")
+	}
+
+	if *ex == nil || (*ex).Panic == nil || (*ex).Panic.Value != "boom" || len((*ex).Panic.Stack) == 0 {
+		t.Fatalf("Expected panic to be dumped with exchange")
+	}
+
+	if (*ex).StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected exchange status 500, got %d", (*ex).StatusCode)
+	}
+}
+
+func TestMiddleware_PanicRespondError(t *testing.T) {
+	m, dump, ex := newPanicMiddleware(httpdump.PanicRespondError)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	v := servePanic(h, rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if v != nil {
+		t.Errorf("Expected panic to be converted to response, got %v", v)
+	}
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected client to get status 500, got %d", rec.Code)
+	}
+
+	if !dump.respDumped ||
+		dump.resp.StatusCode != http.StatusInternalServerError ||
+		string(dump.respBody) != "Internal Server Error\n" {
+		t.Errorf("Unexpected response dump %v %q", dump.respDumped, dump.respBody)
+	}
+
+	if *ex == nil || (*ex).Panic == nil {
+		t.Errorf("Expected panic to be dumped with exchange")
+	}
+
+	if dump.panic == nil || dump.panic.Value != "boom" || len(dump.panic.Stack) == 0 {
+		t.Errorf("Expected panic to be available for response dump, got %+v", dump.panic)
+	}
+}
+
+func TestMiddleware_PanicAfterWrite(t *testing.T) {
+	m, dump, ex := newPanicMiddleware(httpdump.PanicRespondError)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	v := servePanic(h, rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if v != http.ErrAbortHandler {
+		t.Errorf("Expected partially written response to be aborted, got %v", v)
+	}
+
+	if *ex == nil || (*ex).Panic == nil || (*ex).Panic.Value != "boom" {
+		t.Errorf("Expected original panic to be dumped with exchange")
+	}
+
+	if rec.Code != http.StatusAccepted || rec.Body.String() != "partial" {
+		t.Errorf("Unexpected response %d %q", rec.Code, rec.Body)
+	}
+
+	if dump.resp.StatusCode != http.StatusAccepted || string(dump.respBody) != "partial" {
+		t.Errorf("Unexpected response dump %d %q", dump.resp.StatusCode, dump.respBody)
+	}
+}
+
+func TestMiddleware_PanicAbortHandler(t *testing.T) {
+	m, dump, ex := newPanicMiddleware(httpdump.PanicRespondError)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	rec := httptest.NewRecorder()
+	v := servePanic(h, rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if v != http.ErrAbortHandler {
+		t.Errorf("Expected http.ErrAbortHandler to be re-panicked, got %v", v)
+	}
+
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 || rec.Flushed {
+		t.Errorf("Aborted response must not be written, got %d %q", rec.Code, rec.Body)
+	}
+
+	// nothing is sent to client, server aborts connection
+	if !dump.respDumped || dump.resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected aborted response to be dumped with status 500")
+	}
+
+	if *ex == nil || (*ex).Panic == nil || len((*ex).Panic.Stack) != 0 {
+		t.Errorf("Expected aborted handler to be dumped without stack")
+	}
+}
+
+type panicDumpResult struct {
+	httpDumpResult
+	panic *httpdump.PanicInfo
+}
+
+func newPanicMiddleware(mode httpdump.PanicMode) (*httpdump.Middleware, *panicDumpResult, **httpdump.Exchange) {
+	var ex *httpdump.Exchange
+
+	result := &panicDumpResult{}
+
+	m := httpdump.NewMiddleware(
+		nil,
+		func(rp *http.Response, body []byte, duration time.Duration) {
+			result.panic = httpdump.RecoveredPanic(rp)
+			result.respDumped = true
+			result.resp = rp
+			result.respBody = append([]byte(nil), body...)
+			result.respDuration = duration
+		},
+		httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+			ex = e
+		}),
+		httpdump.WithPanicRecovery(mode))
+
+	return m, result, &ex
+}
+
+func servePanic(h http.Handler, w http.ResponseWriter, r *http.Request) (v any) {
+	defer func() {
+		v = recover()
+	}()
+
+	h.ServeHTTP(w, r)
+
+	return nil
+}
@@ -0,0 +1,407 @@
+package httpdump
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultRedactedMask is a value that replaces redacted data.
+const DefaultRedactedMask = "[REDACTED]"
+
+// DefaultRedactedHeaders is a list of headers that are redacted by default.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+// RedactOption is a redaction option that allows to set what data is redacted.
+type RedactOption func(*Redactor)
+
+// RedactHeaders creates a new option that adds headers to redact.
+func RedactHeaders(names ...string) RedactOption {
+	return func(rd *Redactor) {
+		for _, n := range names {
+			rd.headers[http.CanonicalHeaderKey(n)] = struct{}{}
+		}
+	}
+}
+
+// RedactJSONPaths creates a new option that adds JSON paths to redact in
+// application/json and application/*+json bodies. Path is a dot separated list of object keys,
+// "*" matches any key, arrays do not add path segments,
+// so "users.password" matches password of every user in users array.
+func RedactJSONPaths(paths ...string) RedactOption {
+	return func(rd *Redactor) {
+		for _, p := range paths {
+			rd.jsonPaths = append(rd.jsonPaths, strings.Split(p, "."))
+		}
+	}
+}
+
+// RedactFormFields creates a new option that adds form field names to redact in
+// application/x-www-form-urlencoded bodies, request URL query and
+// multipart text fields (see WithMultipartSummary).
+func RedactFormFields(names ...string) RedactOption {
+	return func(rd *Redactor) {
+		for _, n := range names {
+			rd.formFields[n] = struct{}{}
+		}
+	}
+}
+
+// RedactRegexps creates a new option that adds regular expressions,
+// matches are redacted in all dumped bodies.
+func RedactRegexps(regexps ...*regexp.Regexp) RedactOption {
+	return func(rd *Redactor) {
+		rd.regexps = append(rd.regexps, regexps...)
+	}
+}
+
+// RedactMask creates a new option that sets value used to replace redacted data.
+func RedactMask(mask string) RedactOption {
+	return func(rd *Redactor) {
+		rd.mask = mask
+	}
+}
+
+// WithRedaction creates a new option that redacts data passed to dump functions.
+// DefaultRedactedHeaders are always redacted. Redaction works on copies,
+// so actual request and response are never changed.
+func WithRedaction(opts ...RedactOption) Option {
+	rd := NewRedactor(opts...)
+
+	return func(m *Middleware) {
+		m.redactor = rd
+	}
+}
+
+// Redactor masks sensitive data in headers and bodies.
+type Redactor struct {
+	headers    map[string]struct{}
+	jsonPaths  [][]string
+	formFields map[string]struct{}
+	regexps    []*regexp.Regexp
+	mask       string
+}
+
+// NewRedactor creates a new redactor that masks DefaultRedactedHeaders and
+// data set by options.
+func NewRedactor(opts ...RedactOption) *Redactor {
+	rd := &Redactor{
+		headers:    make(map[string]struct{}),
+		formFields: make(map[string]struct{}),
+		mask:       DefaultRedactedMask,
+	}
+
+	RedactHeaders(DefaultRedactedHeaders...)(rd)
+
+	for _, opt := range opts {
+		opt(rd)
+	}
+
+	return rd
+}
+
+// Header returns a copy of headers with sensitive values masked.
+func (rd *Redactor) Header(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+
+	c := h.Clone()
+	for k, vs := range c {
+		if _, ok := rd.headers[k]; !ok {
+			continue
+		}
+
+		for i := range vs {
+			vs[i] = rd.mask
+		}
+	}
+
+	return c
+}
+
+// Request returns a shallow copy of request with redacted headers, URL query,
+// RequestURI and URL password.
+func (rd *Redactor) Request(r *http.Request) *http.Request {
+	rc := new(http.Request)
+	*rc = *r
+	rc.Header = rd.Header(r.Header)
+	rc.Trailer = rd.Header(r.Trailer)
+
+	if r.URL == nil {
+		return rc
+	}
+
+	u := *r.URL
+
+	if len(rd.formFields) > 0 && u.RawQuery != "" {
+		u.RawQuery = string(rd.form([]byte(u.RawQuery)))
+
+		// request line is dumped from RequestURI for server requests
+		if path, _, ok := strings.Cut(r.RequestURI, "?"); ok {
+			rc.RequestURI = path + "?" + u.RawQuery
+		}
+	}
+
+	// client requests may have credentials in URL
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), rd.mask)
+	}
+
+	rc.URL = &u
+
+	return rc
+}
+
+// Body returns a copy of body with sensitive data masked.
+// Content type from headers is used to detect body format,
+// body may be truncated.
+func (rd *Redactor) Body(headers http.Header, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	ct := headers.Get(HeaderContentType)
+
+	switch {
+	case len(rd.jsonPaths) > 0 && isJSONContentType(ct):
+		body = rd.json(body)
+	case len(rd.formFields) > 0 && strings.HasPrefix(ct, MimeApplicationForm):
+		body = rd.form(body)
+	default:
+		body = bytes.Clone(body)
+	}
+
+	for _, re := range rd.regexps {
+		body = re.ReplaceAllLiteral(body, []byte(rd.mask))
+	}
+
+	return body
+}
+
+// isJSONContentType reports whether content type is application/json
+// or has +json suffix, such as application/ld+json.
+func isJSONContentType(ct string) bool {
+	mt, _, _ := strings.Cut(ct, ";")
+	mt = strings.ToLower(strings.TrimSpace(mt))
+
+	return mt == MimeApplicationJSON || (strings.HasPrefix(mt, "application/") && strings.HasSuffix(mt, "+json"))
+}
+
+func (rd *Redactor) form(body []byte) []byte {
+	out := make([]byte, 0, len(body))
+
+	for i, pair := range bytes.Split(body, []byte("&")) {
+		if i > 0 {
+			out = append(out, '&')
+		}
+
+		k, _, hasValue := bytes.Cut(pair, []byte("="))
+
+		name, err := url.QueryUnescape(string(k))
+		if err != nil {
+			name = string(k)
+		}
+
+		if _, ok := rd.formFields[name]; !ok || !hasValue {
+			out = append(out, pair...)
+			continue
+		}
+
+		out = append(out, k...)
+		out = append(out, '=')
+		out = append(out, url.QueryEscape(rd.mask)...)
+	}
+
+	return out
+}
+
+type jsonFrame struct {
+	object    bool
+	expectKey bool
+	items     int
+	path      []string
+}
+
+// json rewrites body token by token, so truncated body is redacted up to
+// the last complete token and partially captured values are never dumped.
+func (rd *Redactor) json(body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	out := bytes.NewBuffer(make([]byte, 0, len(body)))
+	mask := marshalJSON(rd.mask)
+
+	var stack []*jsonFrame
+
+	// path of the next value
+	valuePath := func() []string {
+		if len(stack) == 0 {
+			return nil
+		}
+		return stack[len(stack)-1].path
+	}
+
+	beforeValue := func() {
+		if len(stack) == 0 {
+			return
+		}
+
+		f := stack[len(stack)-1]
+		if !f.object && f.items > 0 {
+			out.WriteByte(',')
+		}
+	}
+
+	afterValue := func() {
+		if len(stack) == 0 {
+			return
+		}
+
+		f := stack[len(stack)-1]
+		f.items++
+		if f.object {
+			f.expectKey = true
+			f.path = f.path[:len(f.path)-1]
+		}
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		if len(stack) > 0 {
+			f := stack[len(stack)-1]
+			if key, ok := tok.(string); ok && f.object && f.expectKey {
+				if f.items > 0 {
+					out.WriteByte(',')
+				}
+
+				out.Write(marshalJSON(key))
+				out.WriteByte(':')
+
+				f.expectKey = false
+				f.path = append(f.path, key)
+				continue
+			}
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				beforeValue()
+
+				p := valuePath()
+				if rd.jsonPathMatched(p) {
+					out.Write(mask)
+					if !skipJSONValue(dec) {
+						return out.Bytes()
+					}
+
+					afterValue()
+					continue
+				}
+
+				out.WriteByte(byte(t))
+				stack = append(stack, &jsonFrame{
+					object:    t == '{',
+					expectKey: t == '{',
+					path:      append([]string(nil), p...),
+				})
+			default:
+				out.WriteByte(byte(t))
+				stack = stack[:len(stack)-1]
+				afterValue()
+			}
+		default:
+			beforeValue()
+
+			if rd.jsonPathMatched(valuePath()) {
+				out.Write(mask)
+			} else {
+				out.Write(marshalJSON(t))
+			}
+
+			afterValue()
+		}
+	}
+
+	return out.Bytes()
+}
+
+func (rd *Redactor) jsonPathMatched(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	for _, jp := range rd.jsonPaths {
+		if len(jp) != len(path) {
+			continue
+		}
+
+		matched := true
+		for i := range jp {
+			if jp[i] != "*" && jp[i] != path[i] {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// marshalJSON marshals token without escaping HTML characters,
+// so dumped body is changed only where it is redacted.
+func marshalJSON(v any) []byte {
+	b := &bytes.Buffer{}
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// skipJSONValue skips tokens up to the end of current object or array,
+// returns false if body ends before that.
+func skipJSONValue(dec *json.Decoder) bool {
+	depth := 1
+	for depth > 0 {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+
+		if d, ok := tok.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				depth++
+			default:
+				depth--
+			}
+		}
+	}
+
+	return true
+}
+
+func (rd *Redactor) formField(name string) bool {
+	_, ok := rd.formFields[name]
+	return ok
+}
@@ -0,0 +1,229 @@
+package replay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+)
+
+// Matcher reports whether recorded exchange matches incoming request,
+// body is a complete body of incoming request.
+type Matcher func(rec *Record, r *http.Request, body []byte) bool
+
+// MatchMethod matches request method.
+func MatchMethod(rec *Record, r *http.Request, _ []byte) bool {
+	return rec.Method == r.Method
+}
+
+// MatchPath matches request URL path.
+func MatchPath(rec *Record, r *http.Request, _ []byte) bool {
+	u, err := url.Parse(rec.URL)
+	if err != nil {
+		return false
+	}
+	return u.Path == r.URL.Path
+}
+
+// MatchQuery matches request URL query, order of parameters is ignored.
+func MatchQuery(rec *Record, r *http.Request, _ []byte) bool {
+	u, err := url.Parse(rec.URL)
+	if err != nil {
+		return false
+	}
+
+	rq, q := u.Query(), r.URL.Query()
+	if len(rq) == 0 && len(q) == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(rq, q)
+}
+
+// MatchBody matches request body. If recorded body is truncated,
+// only recorded prefix is compared.
+func MatchBody(rec *Record, _ *http.Request, body []byte) bool {
+	if rec.RequestTruncated() {
+		return bytes.HasPrefix(body, rec.RequestBody)
+	}
+	return bytes.Equal(rec.RequestBody, body)
+}
+
+// MatchHeaders creates a new matcher that matches values of specified request headers.
+func MatchHeaders(names ...string) Matcher {
+	return func(rec *Record, r *http.Request, _ []byte) bool {
+		for _, n := range names {
+			if !reflect.DeepEqual(rec.RequestHeader.Values(n), r.Header.Values(n)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// DefaultMatchers is a list of matchers used by default.
+var DefaultMatchers = []Matcher{
+	MatchMethod,
+	MatchPath,
+	MatchQuery,
+	MatchBody,
+}
+
+// Order defines which of matching records is served.
+type Order int
+
+const (
+	// OrderFirst serves the first matching record for every request.
+	OrderFirst Order = iota
+	// OrderSequential serves every record only once in recorded order.
+	OrderSequential
+	// OrderCycle serves matching records in turn and starts over
+	// when all of them are served.
+	OrderCycle
+)
+
+// Option is a handler option that allows to override default behaviour of handler.
+type Option func(*Handler)
+
+// WithMatchers creates a new option that replaces DefaultMatchers.
+func WithMatchers(matchers ...Matcher) Option {
+	return func(h *Handler) {
+		h.matchers = matchers
+	}
+}
+
+// WithOrder creates a new option that sets order in which matching records are served.
+func WithOrder(order Order) Option {
+	return func(h *Handler) {
+		h.order = order
+	}
+}
+
+// WithNotFound creates a new option that sets handler for requests
+// without matching record. By default 404 Not Found is responded.
+func WithNotFound(notFound http.Handler) Option {
+	return func(h *Handler) {
+		h.notFound = notFound
+	}
+}
+
+// Handler responds to requests with recorded responses.
+type Handler struct {
+	mu       sync.Mutex
+	records  []Record
+	served   []int
+	matchers []Matcher
+	order    Order
+	notFound http.Handler
+}
+
+// NewHandler creates a new replay handler.
+func NewHandler(records []Record, opts ...Option) *Handler {
+	h := &Handler{
+		records:  records,
+		served:   make([]int, len(records)),
+		matchers: DefaultMatchers,
+		order:    OrderFirst,
+		notFound: http.HandlerFunc(notFound),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rec := h.match(r, body)
+	if rec == nil {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	for k, vs := range rec.ResponseHeader {
+		w.Header()[k] = append([]string(nil), vs...)
+	}
+
+	if rec.ResponseTruncated() {
+		// only part of the body is recorded
+		w.Header().Del("Content-Length")
+	}
+
+	status := rec.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.WriteHeader(status)
+	w.Write(rec.ResponseBody)
+}
+
+// Reset makes all records available again for OrderSequential and OrderCycle.
+func (h *Handler) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clear(h.served)
+}
+
+func (h *Handler) match(r *http.Request, body []byte) *Record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	found := -1
+
+	for i := range h.records {
+		if h.order == OrderSequential && h.served[i] > 0 {
+			continue
+		}
+
+		if !h.matched(&h.records[i], r, body) {
+			continue
+		}
+
+		if h.order != OrderCycle {
+			found = i
+			break
+		}
+
+		// least served record is the next one in turn
+		if found < 0 || h.served[i] < h.served[found] {
+			found = i
+		}
+	}
+
+	if found < 0 {
+		return nil
+	}
+
+	h.served[found]++
+
+	return &h.records[found]
+}
+
+func (h *Handler) matched(rec *Record, r *http.Request, body []byte) bool {
+	for _, m := range h.matchers {
+		if !m(rec, r, body) {
+			return false
+		}
+	}
+	return true
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	http.Error(
+		w,
+		fmt.Sprintf("replay: no recorded exchange for %s %s", r.Method, r.URL),
+		http.StatusNotFound)
+}
@@ -0,0 +1,141 @@
+// Package replay records exchanges captured by httpdump middleware
+// to JSON Lines file and serves them back as a mock server.
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+// Record is a recorded exchange, it is written as a single line of JSON.
+// Bodies are limited by middleware body limit, set it with httpdump.WithLimitedBody
+// to record complete bodies.
+//
+// Response decoded by middleware (see httpdump.WithDecompression) is recorded
+// without Content-Encoding and Content-Length headers, ResponseBytes is a size
+// of decoded body then, it is -1 if decoded body is truncated.
+type Record struct {
+	Time           time.Time     `json:"time"`
+	Duration       time.Duration `json:"duration"`
+	Method         string        `json:"method"`
+	URL            string        `json:"url"`
+	RequestHeader  http.Header   `json:"request_header,omitempty"`
+	RequestBody    []byte        `json:"request_body,omitempty"`
+	RequestBytes   int64         `json:"request_bytes"`
+	StatusCode     int           `json:"status"`
+	ResponseHeader http.Header   `json:"response_header,omitempty"`
+	ResponseBody   []byte        `json:"response_body,omitempty"`
+	ResponseBytes  int64         `json:"response_bytes"`
+}
+
+// NewRecord creates a new record from dumped exchange.
+func NewRecord(ex *httpdump.Exchange) Record {
+	rec := Record{
+		Time:           ex.Start,
+		Duration:       ex.Duration,
+		Method:         ex.Request.Method,
+		URL:            ex.Request.URL.String(),
+		RequestHeader:  ex.Request.Header.Clone(),
+		RequestBody:    append([]byte(nil), ex.RequestBody...),
+		RequestBytes:   ex.RequestBytes,
+		StatusCode:     ex.StatusCode,
+		ResponseHeader: ex.ResponseHeader.Clone(),
+		ResponseBody:   append([]byte(nil), ex.ResponseBody...),
+		ResponseBytes:  ex.ResponseBytes,
+	}
+
+	if enc := ex.ResponseEncoding; enc != nil && enc.Err == nil {
+		// decoded body must not be served as encoded
+		rec.ResponseHeader.Del("Content-Encoding")
+		rec.ResponseHeader.Del("Content-Length")
+
+		rec.ResponseBytes = int64(len(rec.ResponseBody))
+		if enc.Truncated {
+			rec.ResponseBytes = -1
+		}
+	}
+
+	return rec
+}
+
+// RequestTruncated reports whether recorded request body is shorter than actual body.
+func (rec *Record) RequestTruncated() bool {
+	return rec.RequestBytes > int64(len(rec.RequestBody))
+}
+
+// ResponseTruncated reports whether recorded response body is shorter than actual body
+// or size of actual body is unknown.
+func (rec *Record) ResponseTruncated() bool {
+	return rec.ResponseBytes < 0 || rec.ResponseBytes > int64(len(rec.ResponseBody))
+}
+
+// Recorder writes dumped exchanges to underlying writer as JSON Lines.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder creates a new recorder.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// DumpExchange is a httpdump.DumpExchangeFunc that records exchange.
+func (rc *Recorder) DumpExchange(ex *httpdump.Exchange) {
+	rec := NewRecord(ex)
+
+	// there is no way to return error from dump function,
+	// it is saved and returned by Err
+	_ = rc.Write(&rec)
+}
+
+// Write writes record to underlying writer.
+func (rc *Recorder) Write(rec *Record) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.err != nil {
+		return rc.err
+	}
+
+	rc.err = rc.enc.Encode(rec)
+	return rc.err
+}
+
+// Err returns the first error occurred while writing records.
+func (rc *Recorder) Err() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return rc.err
+}
+
+// Load reads all records from JSON Lines capture.
+func Load(r io.Reader) ([]Record, error) {
+	dec := json.NewDecoder(r)
+
+	var records []Record
+	for {
+		var rec Record
+
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+
+		if err != nil {
+			return records, err
+		}
+
+		records = append(records, rec)
+	}
+}
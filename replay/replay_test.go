@@ -0,0 +1,208 @@
+package replay_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/replay"
+)
+
+func TestReplay_RecordAndServe(t *testing.T) {
+	capture := &bytes.Buffer{}
+	rc := replay.NewRecorder(capture)
+
+	m := httpdump.NewMiddleware(nil, nil, httpdump.WithDumpExchange(rc.DumpExchange))
+
+	calls := 0
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		b, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Call", r.URL.Query().Get("call"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created " + string(b)))
+	}))
+
+	post := func(h http.Handler, target, body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/plain")
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec.Result()
+	}
+
+	post(h, "/items?call=1&b=2", "first")
+	post(h, "/items?call=2", "second")
+
+	noerr(t, rc.Err())
+
+	records, err := replay.Load(capture)
+	noerr(t, err)
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	rh := replay.NewHandler(records)
+
+	resp := post(rh, "/items?b=2&call=1", "first")
+	expectResponse(t, resp, http.StatusCreated, "created first")
+
+	if resp.Header.Get("X-Call") != "1" {
+		t.Errorf("Unexpected response headers %v", resp.Header)
+	}
+
+	resp = post(rh, "/items?call=2", "second")
+	expectResponse(t, resp, http.StatusCreated, "created second")
+
+	resp = post(rh, "/items?call=2", "other")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected request with other body not to be matched, got %d", resp.StatusCode)
+	}
+
+	if calls != 2 {
+		t.Errorf("Replay must not call original handler, got %d calls", calls)
+	}
+}
+
+func TestReplay_DecodedResponse(t *testing.T) {
+	body := "decoded response"
+
+	encoded := &bytes.Buffer{}
+	gw := gzip.NewWriter(encoded)
+	gw.Write([]byte(body))
+	noerr(t, gw.Close())
+
+	capture := &bytes.Buffer{}
+	rc := replay.NewRecorder(capture)
+
+	m := httpdump.NewMiddleware(nil, nil,
+		httpdump.WithDecompression(),
+		httpdump.WithDumpExchange(rc.DumpExchange))
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.Itoa(encoded.Len()))
+		w.Write(encoded.Bytes())
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/gzip", nil))
+
+	records, err := replay.Load(capture)
+	noerr(t, err)
+
+	if len(records) != 1 || records[0].ResponseTruncated() {
+		t.Fatalf("Expected complete decoded record, got %+v", records)
+	}
+
+	s := httptest.NewServer(replay.NewHandler(records))
+	defer s.Close()
+
+	resp, err := s.Client().Get(s.URL + "/gzip")
+	noerr(t, err)
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	noerr(t, err)
+
+	if string(b) != body || resp.Header.Get("Content-Encoding") != "" || resp.ContentLength != int64(len(body)) {
+		t.Errorf("Expected decoded body to be served without encoding, got %v %q", resp.Header, b)
+	}
+}
+
+func TestReplay_Order(t *testing.T) {
+	records := []replay.Record{
+		{Method: http.MethodGet, URL: "/next", StatusCode: http.StatusOK, ResponseBody: []byte("1")},
+		{Method: http.MethodGet, URL: "/next", StatusCode: http.StatusOK, ResponseBody: []byte("2")},
+	}
+
+	cases := []struct {
+		order    replay.Order
+		expected []string
+	}{
+		{order: replay.OrderFirst, expected: []string{"1", "1", "1"}},
+		{order: replay.OrderSequential, expected: []string{"1", "2", "replay: no recorded exchange for GET /next\n"}},
+		{order: replay.OrderCycle, expected: []string{"1", "2", "1"}},
+	}
+
+	for _, c := range cases {
+		rh := replay.NewHandler(records, replay.WithOrder(c.order))
+
+		for i, e := range c.expected {
+			rec := httptest.NewRecorder()
+			rh.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/next", http.NoBody))
+
+			if rec.Body.String() != e {
+				t.Errorf("Order %d, request %d: expected %q, got %q", c.order, i, e, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestReplay_Matchers(t *testing.T) {
+	records := []replay.Record{
+		{
+			Method:        http.MethodPut,
+			URL:           "/any",
+			RequestHeader: http.Header{"X-Tenant": []string{"a"}},
+			RequestBody:   []byte("prefix"),
+			RequestBytes:  100,
+			StatusCode:    http.StatusAccepted,
+		},
+	}
+
+	rh := replay.NewHandler(
+		records,
+		replay.WithMatchers(replay.MatchMethod, replay.MatchHeaders("X-Tenant"), replay.MatchBody))
+
+	req := httptest.NewRequest(http.MethodPut, "/other", strings.NewReader("prefix and the rest"))
+	req.Header.Set("X-Tenant", "a")
+
+	rec := httptest.NewRecorder()
+	rh.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("Expected truncated body prefix to be matched, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/other", strings.NewReader("prefix"))
+	req.Header.Set("X-Tenant", "b")
+
+	rec = httptest.NewRecorder()
+	rh.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected request with other header not to be matched, got %d", rec.Code)
+	}
+}
+
+func expectResponse(t *testing.T, resp *http.Response, status int, body string) {
+	t.Helper()
+
+	b, err := io.ReadAll(resp.Body)
+	noerr(t, err)
+
+	if resp.StatusCode != status || string(b) != body {
+		t.Errorf("Expected response %d %q, got %d %q", status, body, resp.StatusCode, b)
+	}
+}
+
+func noerr(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,192 @@
+package ring
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+// Handler lists buffer entries on GET / and shows single entry on GET /{id},
+// mount it with http.StripPrefix to serve it under a path prefix.
+//
+// List is filtered by query parameters:
+//   - path: regexp matched against request path;
+//   - method: request method;
+//   - status: response status code or class, e.g. 404 or 5xx;
+//   - min_duration: minimal duration, e.g. 500ms;
+//   - from, to: range of request start time in RFC 3339 format;
+//   - limit: maximum number of entries.
+//
+// Entries are responded as JSON, or as HTML if format=html parameter
+// is set or HTML is accepted by client.
+type Handler struct {
+	b *Buffer
+}
+
+// NewHandler creates a new handler for buffer.
+func NewHandler(b *Buffer) *Handler {
+	return &Handler{b: b}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.Trim(r.URL.Path, "/")
+	if id != "" {
+		e, ok := h.b.Get(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		respond(w, r, entryTemplate, &e)
+		return
+	}
+
+	q, err := ParseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries := h.b.Entries(q)
+	if entries == nil {
+		entries = []Entry{}
+	}
+
+	respond(w, r, listTemplate, entries)
+}
+
+// ParseQuery parses query from request URL parameters, see Handler.
+func ParseQuery(r *http.Request) (*Query, error) {
+	v := r.URL.Query()
+	q := &Query{
+		Method: strings.ToUpper(v.Get("method")),
+	}
+
+	if p := v.Get("path"); p != "" {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("ring: invalid path: %w", err)
+		}
+		q.Path = re
+	}
+
+	if s := v.Get("status"); s != "" {
+		var err error
+		q.MinStatus, q.MaxStatus, err = parseStatus(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if d := v.Get("min_duration"); d != "" {
+		md, err := time.ParseDuration(d)
+		if err != nil {
+			return nil, fmt.Errorf("ring: invalid min_duration: %w", err)
+		}
+		q.MinDuration = md
+	}
+
+	for _, t := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		s := v.Get(t.name)
+		if s == "" {
+			continue
+		}
+
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("ring: invalid %s: %w", t.name, err)
+		}
+		*t.dst = tm
+	}
+
+	if l := v.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("ring: invalid limit %q", l)
+		}
+		q.Limit = limit
+	}
+
+	return q, nil
+}
+
+func parseStatus(s string) (minStatus, maxStatus int, err error) {
+	if len(s) == 3 && strings.HasSuffix(strings.ToLower(s), "xx") && s[0] >= '1' && s[0] <= '5' {
+		class := int(s[0]-'0') * 100
+		return class, class + 99, nil
+	}
+
+	status, err := strconv.Atoi(s)
+	if err != nil || status < 100 || status > 999 {
+		return 0, 0, fmt.Errorf("ring: invalid status %q", s)
+	}
+
+	return status, status, nil
+}
+
+func respond(w http.ResponseWriter, r *http.Request, tmpl *template.Template, data any) {
+	if !wantsHTML(r) {
+		w.Header().Set("Content-Type", httpdump.MimeApplicationJSON)
+		_ = json.NewEncoder(w).Encode(data)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = tmpl.Execute(w, data)
+}
+
+func wantsHTML(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "html"
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+var funcs = template.FuncMap{
+	"text": func(b []byte) string { return string(b) },
+	"time": func(t time.Time) string { return t.Format(time.RFC3339Nano) },
+}
+
+var listTemplate = template.Must(template.New("list").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html><head><title>httpdump</title></head><body>
+<table>
+<tr><th>Time</th><th>Method</th><th>URL</th><th>Status</th><th>Duration</th></tr>
+{{range .}}<tr><td><a href="{{.ID}}?format=html">{{time .Time}}</a></td><td>{{.Method}}</td><td>{{.URL}}</td><td>{{.StatusCode}}</td><td>{{.Duration}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+var entryTemplate = template.Must(template.New("entry").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html><head><title>httpdump {{.ID}}</title></head><body>
+<h1>{{.Method}} {{.URL}}</h1>
+<p>{{time .Time}}, {{.Duration}}</p>
+<h2>Request</h2>
+<pre>{{range $k, $v := .RequestHeader}}{{range $v}}{{$k}}: {{.}}
+{{end}}{{end}}
+{{text .RequestBody}}</pre>
+{{if .RequestTruncated}}<p>Body is truncated, {{.RequestBytes}} bytes total.</p>{{end}}
+<h2>Response {{.StatusCode}}</h2>
+<pre>{{range $k, $v := .ResponseHeader}}{{range $v}}{{$k}}: {{.}}
+{{end}}{{end}}
+{{text .ResponseBody}}</pre>
+{{if .ResponseTruncated}}<p>Body is truncated, {{.ResponseBytes}} bytes total.</p>{{end}}
+</body></html>
+`))
@@ -0,0 +1,264 @@
+// Package ring keeps recent exchanges captured by httpdump middleware
+// in a bounded in-memory buffer and serves them for live debugging.
+package ring
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/replay"
+)
+
+const (
+	// DefaultSize is a default maximum number of entries in buffer.
+	DefaultSize = 1000
+	// DefaultMemoryLimit is a default maximum size of entries in buffer.
+	DefaultMemoryLimit = 64 << 20
+
+	// entryOverhead is an approximate size of entry without bodies and headers
+	entryOverhead = 256
+)
+
+// Entry is an exchange kept in buffer.
+type Entry struct {
+	// ID is a request id (see httpdump.RequestID) or a buffer sequence number
+	// if request id is unknown.
+	ID string `json:"id"`
+	replay.Record
+}
+
+// Option is a buffer option that allows to override default buffer limits.
+type Option func(*Buffer)
+
+// WithSize creates a new option that sets maximum number of entries in buffer.
+func WithSize(size int) Option {
+	return func(b *Buffer) {
+		b.size = size
+	}
+}
+
+// WithMaxAge creates a new option that evicts entries older than maxAge,
+// by default entries are evicted only by size and memory limits.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(b *Buffer) {
+		b.maxAge = maxAge
+	}
+}
+
+// WithMemoryLimit creates a new option that sets maximum approximate size
+// of entries in buffer, oldest entries are evicted when limit is exceeded.
+func WithMemoryLimit(limit int) Option {
+	return func(b *Buffer) {
+		b.memLimit = limit
+	}
+}
+
+// Buffer keeps last exchanges, oldest entries are evicted when
+// size, memory limit or max age is exceeded. Safe to use from multiple goroutines.
+type Buffer struct {
+	mu       sync.Mutex
+	entries  []Entry
+	sizes    []int
+	head     int
+	count    int
+	mem      int
+	seq      uint64
+	size     int
+	maxAge   time.Duration
+	memLimit int
+}
+
+// New creates a new buffer.
+func New(opts ...Option) *Buffer {
+	b := &Buffer{
+		size:     DefaultSize,
+		memLimit: DefaultMemoryLimit,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	b.entries = make([]Entry, b.size)
+	b.sizes = make([]int, b.size)
+
+	return b
+}
+
+// DumpExchange is a httpdump.DumpExchangeFunc that adds exchange to buffer.
+func (b *Buffer) DumpExchange(ex *httpdump.Exchange) {
+	b.Add(Entry{
+		ID:     httpdump.RequestID(ex.Request),
+		Record: replay.NewRecord(ex),
+	})
+}
+
+// Add adds entry to buffer, entry with empty ID gets buffer sequence number.
+// Entry larger than memory limit is not added.
+func (b *Buffer) Add(e Entry) {
+	size := entrySize(&e)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	if e.ID == "" {
+		e.ID = strconv.FormatUint(b.seq, 10)
+	}
+
+	if b.size <= 0 || size > b.memLimit {
+		return
+	}
+
+	for b.count == b.size || b.mem+size > b.memLimit {
+		b.evict()
+	}
+
+	i := (b.head + b.count) % b.size
+	b.entries[i] = e
+	b.sizes[i] = size
+	b.count++
+	b.mem += size
+
+	b.evictExpired(time.Now())
+}
+
+// Len returns number of entries in buffer.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.evictExpired(time.Now())
+
+	return b.count
+}
+
+// Get returns entry with specified id.
+func (b *Buffer) Get(id string) (Entry, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.evictExpired(time.Now())
+
+	for i := 0; i < b.count; i++ {
+		e := &b.entries[(b.head+i)%b.size]
+		if e.ID == id {
+			return *e, true
+		}
+	}
+
+	return Entry{}, false
+}
+
+// Entries returns entries matching query, newest entries go first.
+func (b *Buffer) Entries(q *Query) []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.evictExpired(time.Now())
+
+	var entries []Entry
+	for i := b.count - 1; i >= 0; i-- {
+		if q.Limit > 0 && len(entries) == q.Limit {
+			break
+		}
+
+		e := &b.entries[(b.head+i)%b.size]
+		if q.Match(e) {
+			entries = append(entries, *e)
+		}
+	}
+
+	return entries
+}
+
+func (b *Buffer) evict() {
+	b.mem -= b.sizes[b.head]
+	b.entries[b.head] = Entry{}
+	b.head = (b.head + 1) % b.size
+	b.count--
+}
+
+func (b *Buffer) evictExpired(now time.Time) {
+	if b.maxAge <= 0 {
+		return
+	}
+
+	for b.count > 0 && now.Sub(b.entries[b.head].Time) > b.maxAge {
+		b.evict()
+	}
+}
+
+func entrySize(e *Entry) int {
+	return entryOverhead + len(e.URL) +
+		len(e.RequestBody) + headerSize(e.RequestHeader) +
+		len(e.ResponseBody) + headerSize(e.ResponseHeader)
+}
+
+func headerSize(h http.Header) int {
+	n := 0
+	for k, vv := range h {
+		n += len(k)
+		for _, v := range vv {
+			n += len(v)
+		}
+	}
+
+	return n
+}
+
+// Query selects buffer entries, zero fields match any entry.
+type Query struct {
+	// Path is matched against request URL path.
+	Path   *regexp.Regexp
+	Method string
+	// MinStatus and MaxStatus is an inclusive range of response status codes.
+	MinStatus   int
+	MaxStatus   int
+	MinDuration time.Duration
+	// From and To is a range of request start time.
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+// Match reports whether entry matches query.
+func (q *Query) Match(e *Entry) bool {
+	if q.Method != "" && q.Method != e.Method {
+		return false
+	}
+
+	if q.MinStatus != 0 && e.StatusCode < q.MinStatus ||
+		q.MaxStatus != 0 && e.StatusCode > q.MaxStatus {
+		return false
+	}
+
+	if e.Duration < q.MinDuration {
+		return false
+	}
+
+	if !q.From.IsZero() && e.Time.Before(q.From) ||
+		!q.To.IsZero() && e.Time.After(q.To) {
+		return false
+	}
+
+	if q.Path != nil && !q.Path.MatchString(entryPath(e)) {
+		return false
+	}
+
+	return true
+}
+
+func entryPath(e *Entry) string {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return e.URL
+	}
+
+	return u.Path
+}
@@ -0,0 +1,156 @@
+package ring_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/replay"
+	"github.com/hummerd/httpdump/ring"
+)
+
+func TestBuffer_Eviction(t *testing.T) {
+	now := time.Now()
+
+	entry := func(path string, age time.Duration, body int) ring.Entry {
+		return ring.Entry{Record: replay.Record{
+			Time:         now.Add(-age),
+			Method:       http.MethodGet,
+			URL:          "http://example.com" + path,
+			ResponseBody: make([]byte, body),
+		}}
+	}
+
+	b := ring.New(ring.WithSize(3))
+	for _, p := range []string{"/1", "/2", "/3", "/4"} {
+		b.Add(entry(p, 0, 0))
+	}
+
+	expectPaths(t, b.Entries(&ring.Query{}), "/4", "/3", "/2")
+
+	b = ring.New(ring.WithMemoryLimit(2000))
+	b.Add(entry("/1", 0, 500))
+	b.Add(entry("/2", 0, 100))
+	b.Add(entry("/3", 0, 500))
+	b.Add(entry("/big", 0, 2000))
+	b.Add(entry("/4", 0, 500))
+
+	expectPaths(t, b.Entries(&ring.Query{}), "/4", "/3", "/2")
+
+	b = ring.New(ring.WithMaxAge(time.Minute))
+	b.Add(entry("/1", time.Hour, 0))
+	b.Add(entry("/2", 0, 0))
+
+	expectPaths(t, b.Entries(&ring.Query{}), "/2")
+
+	if b.Len() != 1 {
+		t.Errorf("Expected 1 entry, got %d", b.Len())
+	}
+}
+
+func TestBuffer_Entries(t *testing.T) {
+	now := time.Now()
+	b := ring.New()
+
+	for _, r := range []replay.Record{
+		{Method: http.MethodGet, URL: "/users/1", StatusCode: 200, Duration: time.Millisecond, Time: now.Add(-3 * time.Minute)},
+		{Method: http.MethodPost, URL: "/users", StatusCode: 500, Duration: time.Second, Time: now.Add(-2 * time.Minute)},
+		{Method: http.MethodGet, URL: "/orders/1?x=/users", StatusCode: 404, Duration: time.Second, Time: now.Add(-time.Minute)},
+	} {
+		b.Add(ring.Entry{Record: r})
+	}
+
+	tests := []struct {
+		query ring.Query
+		paths []string
+	}{
+		{ring.Query{}, []string{"/orders/1?x=/users", "/users", "/users/1"}},
+		{ring.Query{Path: regexp.MustCompile("^/users")}, []string{"/users", "/users/1"}},
+		{ring.Query{Method: http.MethodGet}, []string{"/orders/1?x=/users", "/users/1"}},
+		{ring.Query{MinStatus: 400, MaxStatus: 499}, []string{"/orders/1?x=/users"}},
+		{ring.Query{MinDuration: time.Second}, []string{"/orders/1?x=/users", "/users"}},
+		{ring.Query{From: now.Add(-150 * time.Second), To: now.Add(-90 * time.Second)}, []string{"/users"}},
+		{ring.Query{Limit: 1}, []string{"/orders/1?x=/users"}},
+	}
+
+	for _, tt := range tests {
+		expectPaths(t, b.Entries(&tt.query), tt.paths...)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	b := ring.New()
+
+	m := httpdump.NewMiddleware(nil, nil, httpdump.WithDumpExchange(b.DumpExchange))
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpdump.MimeTextPlain)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte("<b>" + r.URL.Path + "</b>"))
+	}))
+
+	for _, p := range []string{"/ok", "/fail"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+
+	rh := http.StripPrefix("/dumps", ring.NewHandler(b))
+
+	rec := get(rh, "/dumps/?status=5xx")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var entries []ring.Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].StatusCode != http.StatusInternalServerError || entries[0].ID == "" {
+		t.Fatalf("Unexpected entries %+v", entries)
+	}
+
+	rec = get(rh, "/dumps/"+entries[0].ID+"?format=html")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "&lt;b&gt;/fail&lt;/b&gt;") {
+		t.Errorf("Unexpected entry page %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = get(rh, "/dumps/")
+	if !strings.Contains(rec.Body.String(), "/ok") || !strings.Contains(rec.Body.String(), "/fail") {
+		t.Errorf("Expected all entries to be listed, got %s", rec.Body.String())
+	}
+
+	if rec = get(rh, "/dumps/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown entry not to be found, got %d", rec.Code)
+	}
+
+	for _, q := range []string{"status=6xx", "path=(", "min_duration=x", "from=yesterday", "limit=-1"} {
+		if rec = get(rh, "/dumps/?"+q); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected query %s to be rejected, got %d", q, rec.Code)
+		}
+	}
+}
+
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func expectPaths(t *testing.T, entries []ring.Entry, paths ...string) {
+	t.Helper()
+
+	got := make([]string, 0, len(entries))
+	for _, e := range entries {
+		got = append(got, strings.TrimPrefix(e.URL, "http://example.com"))
+	}
+
+	if strings.Join(got, ",") != strings.Join(paths, ",") {
+		t.Errorf("Expected entries %v, got %v", paths, got)
+	}
+}
@@ -0,0 +1,172 @@
+package httpdump
+
+import (
+	"math/rand"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// RouteSample is a sampling ratio for requests with matching path.
+type RouteSample struct {
+	Pattern *regexp.Regexp
+	Ratio   float64
+}
+
+// SampleRequests creates a new request filter that dumps only specified ratio
+// of requests (from 0 to 1). Responses and exchanges of not sampled requests
+// are not dumped either, unless they are kept by KeepErrorsAndSlow.
+// Pass seeded source to get deterministic sampling,
+// if source is nil then time seeded source is used.
+func SampleRequests(ratio float64, src rand.Source) RequestFilterFunc {
+	s := newSampler(src)
+
+	return func(r *http.Request) (bool, bool) {
+		return sampled(r, s.sample(ratio))
+	}
+}
+
+// SampleRoutes creates a new request filter that samples requests with
+// ratio of the first route which pattern matches request path,
+// fallback ratio is used if there is no matching route.
+func SampleRoutes(routes []RouteSample, fallback float64, src rand.Source) RequestFilterFunc {
+	s := newSampler(src)
+
+	return func(r *http.Request) (bool, bool) {
+		ratio := fallback
+		for _, rs := range routes {
+			if rs.Pattern.MatchString(r.URL.Path) {
+				ratio = rs.Ratio
+				break
+			}
+		}
+
+		return sampled(r, s.sample(ratio))
+	}
+}
+
+// LimitRequests creates a new request filter that dumps at most perSecond
+// requests per second with bursts of up to burst requests.
+func LimitRequests(perSecond float64, burst int) RequestFilterFunc {
+	if perSecond <= 0 || burst <= 0 {
+		panic("httpdump: rate and burst must be greater than 0")
+	}
+
+	tb := &tokenBucket{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+
+	return func(r *http.Request) (bool, bool) {
+		return sampled(r, tb.take(time.Now()))
+	}
+}
+
+// KeepErrorsAndSlow creates a new response filter that keeps responses of
+// requests not sampled by sampling filters (SampleRequests, SampleRoutes, LimitRequests
+// and Settings.SampleRate), which are dropped otherwise, if response status is at least
+// minStatus or response headers were written later than slow after request start.
+// Zero minStatus or slow disables the check.
+//
+// Kept responses of not sampled requests are dumped with exchange,
+// but without request body.
+func KeepErrorsAndSlow(minStatus int, slow time.Duration) ResponseFilterFunc {
+	return func(r *http.Request, _ http.Header, status int) (bool, bool) {
+		st := stateFromRequest(r)
+		if st == nil || !st.unsampled {
+			return true, true
+		}
+
+		if (minStatus > 0 && status >= minStatus) ||
+			(slow > 0 && time.Since(st.start) >= slow) {
+			st.kept = true
+			return true, true
+		}
+
+		return false, false
+	}
+}
+
+// sampledOut reports whether request is not sampled
+// and its response is not kept by response filters.
+func sampledOut(r *http.Request) bool {
+	st := stateFromRequest(r)
+	return st != nil && st.unsampled && !st.kept
+}
+
+// keptUnsampled reports whether request is not sampled,
+// but its response is kept by response filters.
+func keptUnsampled(r *http.Request) bool {
+	st := stateFromRequest(r)
+	return st != nil && st.unsampled && st.kept
+}
+
+func sampled(r *http.Request, ok bool) (bool, bool) {
+	if !ok {
+		if st := stateFromRequest(r); st != nil {
+			st.unsampled = true
+		}
+	}
+
+	return ok, ok
+}
+
+type sampler struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newSampler(src rand.Source) *sampler {
+	if src == nil {
+		src = rand.NewSource(time.Now().UnixNano())
+	}
+
+	return &sampler{
+		rnd: rand.New(src),
+	}
+}
+
+func (s *sampler) sample(ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+
+	if ratio <= 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	f := s.rnd.Float64()
+	s.mu.Unlock()
+
+	return f < ratio
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (tb *tokenBucket) take(now time.Time) bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+
+	if tb.tokens < 1 {
+		return false
+	}
+
+	tb.tokens--
+	return true
+}
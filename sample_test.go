@@ -0,0 +1,173 @@
+package httpdump_test
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestSampleRequests_Deterministic(t *testing.T) {
+	f1 := httpdump.SampleRequests(0.5, rand.NewSource(1))
+	f2 := httpdump.SampleRequests(0.5, rand.NewSource(1))
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+
+	n := 0
+	for i := 0; i < 1000; i++ {
+		d1, b1 := f1(req)
+		d2, _ := f2(req)
+
+		if d1 != d2 {
+			t.Fatalf("Expected the same decision for the same seed at %d", i)
+		}
+
+		if d1 != b1 {
+			t.Fatalf("Expected body to be dumped only for sampled request")
+		}
+
+		if d1 {
+			n++
+		}
+	}
+
+	if n < 400 || n > 600 {
+		t.Errorf("Expected about 500 sampled requests, got %d", n)
+	}
+
+	if d, _ := httpdump.SampleRequests(0, nil)(req); d {
+		t.Errorf("Expected request not to be sampled with ratio 0")
+	}
+
+	if d, _ := httpdump.SampleRequests(1, nil)(req); !d {
+		t.Errorf("Expected request to be sampled with ratio 1")
+	}
+}
+
+func TestSampleRoutes(t *testing.T) {
+	f := httpdump.SampleRoutes(
+		[]httpdump.RouteSample{
+			{Pattern: regexp.MustCompile("^/health"), Ratio: 0},
+			{Pattern: regexp.MustCompile("^/api/"), Ratio: 1},
+		},
+		0,
+		rand.NewSource(1))
+
+	cases := map[string]bool{
+		"/health":   false,
+		"/api/user": true,
+		"/other":    false,
+	}
+
+	for p, expected := range cases {
+		d, _ := f(httptest.NewRequest(http.MethodGet, p, http.NoBody))
+		if d != expected {
+			t.Errorf("Expected %s to be sampled %v, got %v", p, expected, d)
+		}
+	}
+}
+
+func TestLimitRequests(t *testing.T) {
+	f := httpdump.LimitRequests(0.001, 2)
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+
+	expected := []bool{true, true, false, false}
+	for i, e := range expected {
+		if d, _ := f(req); d != e {
+			t.Errorf("Expected request %d to be dumped %v, got %v", i, e, d)
+		}
+	}
+}
+
+func TestMiddleware_SampleRequests(t *testing.T) {
+	var requests, responses, exchanges int
+
+	m := httpdump.NewMiddleware(
+		func(rq *http.Request, body []byte) {
+			requests++
+		},
+		func(rp *http.Response, body []byte, _ time.Duration) {
+			responses++
+		},
+		httpdump.WithRequestFilters(httpdump.SampleRequests(0, nil)),
+		httpdump.WithDumpExchange(func(ex *httpdump.Exchange) {
+			exchanges++
+		}),
+	)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	for i := 0; i < 10; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}
+
+	if requests != 0 || responses != 0 || exchanges != 0 {
+		t.Errorf("Expected not sampled requests not to be dumped, got %d requests, %d responses and %d exchanges",
+			requests, responses, exchanges)
+	}
+
+	if s := m.Stats(); s.Filtered != 10 {
+		t.Errorf("Expected 10 filtered requests, got %d", s.Filtered)
+	}
+}
+
+func TestMiddleware_KeepErrorsAndSlow(t *testing.T) {
+	var exchanges []*httpdump.Exchange
+
+	m, dump := newMiddleware(true, true, []httpdump.Option{
+		httpdump.WithRequestFilters(httpdump.SampleRequests(0, nil)),
+		httpdump.WithResponseFilters(httpdump.KeepErrorsAndSlow(http.StatusInternalServerError, 50*time.Millisecond)),
+		httpdump.WithDumpExchange(func(ex *httpdump.Exchange) {
+			exchanges = append(exchanges, ex)
+		}),
+	})
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			time.Sleep(60 * time.Millisecond)
+		}
+
+		w.Write([]byte("response"))
+	}))
+
+	serve := func(p string) {
+		req := httptest.NewRequest(http.MethodPost, p, strings.NewReader("request"))
+		req.Header.Set("Content-Type", "text/plain")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve("/ok")
+
+	if dump.reqDumped || dump.respDumped || len(exchanges) != 0 {
+		t.Fatalf("Expected not sampled request not to be dumped")
+	}
+
+	for _, p := range []string{"/error", "/slow"} {
+		dump.respDumped = false
+
+		serve(p)
+
+		if dump.reqDumped || !dump.respDumped {
+			t.Errorf("Expected only response to be dumped for %s", p)
+		}
+	}
+
+	if len(exchanges) != 2 {
+		t.Fatalf("Expected 2 exchanges, got %d", len(exchanges))
+	}
+
+	if exchanges[0].StatusCode != http.StatusInternalServerError || exchanges[0].RequestBody != nil {
+		t.Errorf("Unexpected exchange %d %q", exchanges[0].StatusCode, exchanges[0].RequestBody)
+	}
+}
@@ -0,0 +1,169 @@
+package httpdump
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// Settings is a part of middleware configuration that can be changed at runtime.
+type Settings struct {
+	// Enabled is a middleware enabled state.
+	Enabled bool
+	// BodyLimit is a limit for dumped body size, see WithLimitedBody.
+	// It must not be greater than MaxBodySize.
+	BodyLimit int
+	// ExcludedPaths is a list of regexps, requests with matching path
+	// are not dumped, see WithPathFilter.
+	ExcludedPaths []*regexp.Regexp
+	// SampleRate is a ratio of dumped requests (from 0 to 1),
+	// it is applied after all request filters. Responses and exchanges
+	// of not sampled requests are not dumped, unless they are kept by KeepErrorsAndSlow.
+	SampleRate float64
+}
+
+var (
+	errBodyLimit  = fmt.Errorf("httpdump: body limit must be between 1 and %d", MaxBodySize)
+	errSampleRate = errors.New("httpdump: sample rate must be between 0 and 1")
+)
+
+// config is a part of middleware configuration that can be changed at runtime.
+// Stored config is never modified, changes are made to a copy that replaces it.
+type config struct {
+	enabled         bool
+	bodySize        int
+	sampleRate      float64
+	sampler         *sampler
+	excludedPaths   []*regexp.Regexp
+	requestFilters  []RequestFilterFunc
+	responseFilters []ResponseFilterFunc
+	activations     []Activation
+}
+
+func (c *config) clone() *config {
+	cc := *c
+	cc.excludedPaths = append([]*regexp.Regexp(nil), c.excludedPaths...)
+	cc.requestFilters = append([]RequestFilterFunc(nil), c.requestFilters...)
+	cc.responseFilters = append([]ResponseFilterFunc(nil), c.responseFilters...)
+	cc.activations = append([]Activation(nil), c.activations...)
+	return &cc
+}
+
+func (c *config) excluded(r *http.Request) bool {
+	for _, re := range c.excludedPaths {
+		if re.MatchString(r.URL.Path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *config) requestPassed(r *http.Request) (dump, body bool) {
+	if activated(r) {
+		return true, true
+	}
+
+	if c.excluded(r) {
+		return false, false
+	}
+
+	dump, body = filterPassed(r, c.requestFilters)
+	if dump && !c.sampler.sample(c.sampleRate) {
+		return sampled(r, false)
+	}
+
+	return dump, body
+}
+
+func (c *config) responsePassed(r *http.Request, headers http.Header, status int) (dump, body bool) {
+	if activated(r) {
+		return true, true
+	}
+
+	if c.excluded(r) {
+		return false, false
+	}
+
+	dump, body = filterResponsePassed(r, headers, status, c.responseFilters)
+	if dump && sampledOut(r) {
+		// responses of not sampled requests are dropped,
+		// unless response filter keeps them, see KeepErrorsAndSlow
+		return false, false
+	}
+
+	return dump, body
+}
+
+// Settings returns current middleware settings. Safe to call from multiple goroutines.
+func (m *Middleware) Settings() Settings {
+	c := m.config.Load()
+
+	return Settings{
+		Enabled:       c.enabled,
+		BodyLimit:     c.bodySize,
+		ExcludedPaths: append([]*regexp.Regexp(nil), c.excludedPaths...),
+		SampleRate:    c.sampleRate,
+	}
+}
+
+// UpdateSettings calls update with current settings and applies changed settings,
+// all changes take effect at once for subsequent requests. Requests that are being
+// handled keep using previous settings. Safe to call from multiple goroutines.
+func (m *Middleware) UpdateSettings(update func(s *Settings)) error {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	s := m.Settings()
+	update(&s)
+
+	if s.BodyLimit <= 0 || s.BodyLimit > MaxBodySize {
+		return errBodyLimit
+	}
+
+	if s.SampleRate < 0 || s.SampleRate > 1 {
+		return errSampleRate
+	}
+
+	c := m.config.Load().clone()
+	c.enabled = s.Enabled
+	c.bodySize = s.BodyLimit
+	c.excludedPaths = append([]*regexp.Regexp(nil), s.ExcludedPaths...)
+	c.sampleRate = s.SampleRate
+
+	m.config.Store(c)
+
+	return nil
+}
+
+// SetRequestFilters replaces request filters set by options
+// (including default content type filter). Safe to call from multiple goroutines.
+func (m *Middleware) SetRequestFilters(filters ...RequestFilterFunc) {
+	m.updateConfig(func(c *config) {
+		c.requestFilters = filters
+	})
+}
+
+// SetResponseFilters replaces response filters set by options
+// (including default content type filter). Safe to call from multiple goroutines.
+func (m *Middleware) SetResponseFilters(filters ...ResponseFilterFunc) {
+	m.updateConfig(func(c *config) {
+		c.responseFilters = filters
+	})
+}
+
+func (m *Middleware) updateConfig(update func(c *config)) {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	c := m.config.Load().clone()
+	update(c)
+	m.config.Store(c)
+}
+
+// initialConfig returns config that is modified by options,
+// it is not shared until middleware is created.
+func (m *Middleware) initialConfig() *config {
+	return m.config.Load()
+}
@@ -0,0 +1,117 @@
+package httpdump_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_UpdateSettings(t *testing.T) {
+	m, dump := newMiddleware(true, true, []httpdump.Option{
+		httpdump.WithResponseFilters(httpdump.KeepErrorsAndSlow(http.StatusInternalServerError, 0)),
+	})
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	serve := func(p string) {
+		dump.reqDumped, dump.respDumped = false, false
+
+		req := httptest.NewRequest(http.MethodPost, p, strings.NewReader("request body"))
+		req.Header.Set("Content-Type", httpdump.MimeTextPlain)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	noerr(t, m.UpdateSettings(func(s *httpdump.Settings) {
+		s.SampleRate = 0
+		s.BodyLimit = 4
+	}))
+
+	serve("/ok")
+
+	if dump.reqDumped || dump.respDumped {
+		t.Errorf("Expected not sampled request not to be dumped")
+	}
+
+	serve("/error")
+
+	if dump.reqDumped || !dump.respDumped {
+		t.Errorf("Expected error response of not sampled request to be dumped")
+	}
+
+	noerr(t, m.UpdateSettings(func(s *httpdump.Settings) {
+		s.SampleRate = 1
+	}))
+
+	m.SetRequestFilters()
+
+	serve("/ok")
+
+	if !dump.reqDumped || string(dump.reqBody) != "requ" {
+		t.Errorf("Expected request body to be dumped with new limit, got %q", dump.reqBody)
+	}
+
+	for _, limit := range []int{-1, httpdump.MaxBodySize + 1} {
+		err := m.UpdateSettings(func(s *httpdump.Settings) {
+			s.SampleRate = 0
+			s.BodyLimit = limit
+		})
+		if err == nil {
+			t.Fatalf("Expected invalid body limit %d to be rejected", limit)
+		}
+	}
+
+	if s := m.Settings(); s.SampleRate != 1 || s.BodyLimit != 4 {
+		t.Errorf("Expected settings not to be changed, got %+v", s)
+	}
+}
+
+func TestWithLimitedBody_Invalid(t *testing.T) {
+	for _, limit := range []int{0, httpdump.MaxBodySize + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected panic for body limit %d", limit)
+				}
+			}()
+
+			httpdump.WithLimitedBody(limit)
+		}()
+	}
+}
+
+func TestMiddleware_UpdateSettingsSampleRate(t *testing.T) {
+	var responses, exchanges int
+
+	m := httpdump.NewMiddleware(
+		nil,
+		func(rp *http.Response, body []byte, _ time.Duration) {
+			responses++
+		},
+		httpdump.WithDumpExchange(func(ex *httpdump.Exchange) {
+			exchanges++
+		}),
+	)
+
+	noerr(t, m.UpdateSettings(func(s *httpdump.Settings) {
+		s.SampleRate = 0
+	}))
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 10; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}
+
+	if responses != 0 || exchanges != 0 {
+		t.Errorf("Expected nothing to be dumped with zero sample rate, got %d responses and %d exchanges",
+			responses, exchanges)
+	}
+}
@@ -0,0 +1,26 @@
+package slogdump_test
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/slogdump"
+)
+
+func Example() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	dumpReq, dumpResp := slogdump.Funcs(
+		logger,
+		slogdump.WithLevel(slog.LevelInfo),
+		slogdump.WithStatusLevel(5, slog.LevelError),
+		slogdump.WithHeaders("Content-Type", "User-Agent"),
+		slogdump.WithBodyPolicy(slogdump.BodyJSON),
+	)
+
+	m := httpdump.NewMiddleware(dumpReq, dumpResp)
+
+	http.Handle("/", m.Wrap(http.NotFoundHandler()))
+}
@@ -0,0 +1,281 @@
+// Package slogdump logs requests and responses dumped by httpdump middleware with log/slog.
+package slogdump
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+const (
+	DefaultRequestMessage  = "HTTP request"
+	DefaultResponseMessage = "HTTP response"
+)
+
+// BodyPolicy defines how dumped body is logged.
+type BodyPolicy int
+
+const (
+	// BodyString logs body as string.
+	BodyString BodyPolicy = iota
+	// BodyBase64 logs body as base64 encoded string.
+	BodyBase64
+	// BodyJSON embeds body as JSON value if it is a valid JSON,
+	// otherwise body is logged as string.
+	BodyJSON
+)
+
+// Option is a logger option that allows to override default logger settings.
+type Option func(*Logger)
+
+// WithLevel creates a new option that sets level for requests and responses.
+// Default level is slog.LevelDebug.
+func WithLevel(level slog.Level) Option {
+	return func(l *Logger) {
+		l.level = level
+	}
+}
+
+// WithStatusLevel creates a new option that sets level for responses
+// of specified status class, e.g. 5 for 5xx responses.
+func WithStatusLevel(class int, level slog.Level) Option {
+	if class < 1 || class > 5 {
+		panic("slogdump: status class must be between 1 and 5")
+	}
+
+	return func(l *Logger) {
+		l.statusLevels[class] = &level
+	}
+}
+
+// WithGroup creates a new option that puts all attributes in group with specified name.
+func WithGroup(name string) Option {
+	return func(l *Logger) {
+		l.group = name
+	}
+}
+
+// WithHeaders creates a new option that logs only specified headers.
+// By default all headers are logged, without names no headers are logged.
+func WithHeaders(names ...string) Option {
+	return func(l *Logger) {
+		l.headers = make([]string, 0, len(names))
+		for _, n := range names {
+			l.headers = append(l.headers, http.CanonicalHeaderKey(n))
+		}
+	}
+}
+
+// WithBodyPolicy creates a new option that sets how body is logged.
+func WithBodyPolicy(policy BodyPolicy) Option {
+	return func(l *Logger) {
+		l.bodyPolicy = policy
+	}
+}
+
+// WithDurationUnit creates a new option that logs response duration
+// as a number of specified units, e.g. time.Millisecond.
+// By default duration is logged as time.Duration.
+func WithDurationUnit(unit time.Duration) Option {
+	if unit <= 0 {
+		panic("slogdump: duration unit must be greater than 0")
+	}
+
+	return func(l *Logger) {
+		l.durationUnit = unit
+	}
+}
+
+// WithMessages creates a new option that sets messages of request and response records.
+func WithMessages(request, response string) Option {
+	return func(l *Logger) {
+		l.requestMsg = request
+		l.responseMsg = response
+	}
+}
+
+// WithRequestID creates a new option that sets function returning request id,
+// it is logged with both request and response. By default httpdump.RequestID is used.
+func WithRequestID(requestID func(r *http.Request) string) Option {
+	return func(l *Logger) {
+		l.requestID = requestID
+	}
+}
+
+// Logger logs dumped requests and responses.
+type Logger struct {
+	logger       *slog.Logger
+	level        slog.Level
+	statusLevels [6]*slog.Level
+	group        string
+	headers      []string
+	bodyPolicy   BodyPolicy
+	durationUnit time.Duration
+	requestMsg   string
+	responseMsg  string
+	requestID    func(r *http.Request) string
+}
+
+// New creates a new logger that logs dumps with specified slog logger,
+// slog.Default() is used if logger is nil.
+func New(logger *slog.Logger, opts ...Option) *Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	l := &Logger{
+		logger:      logger,
+		level:       slog.LevelDebug,
+		requestMsg:  DefaultRequestMessage,
+		responseMsg: DefaultResponseMessage,
+		requestID:   httpdump.RequestID,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Funcs creates a new logger and returns its dump functions,
+// they can be passed directly to httpdump.NewMiddleware.
+func Funcs(logger *slog.Logger, opts ...Option) (httpdump.DumpRequestFunc, httpdump.DumpResponseFunc) {
+	l := New(logger, opts...)
+	return l.DumpRequest, l.DumpResponse
+}
+
+// DumpRequest is a httpdump.DumpRequestFunc that logs request.
+func (l *Logger) DumpRequest(rq *http.Request, body []byte) {
+	ctx := rq.Context()
+	if !l.logger.Enabled(ctx, l.level) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 5)
+	attrs = l.appendRequest(attrs, rq)
+	attrs = l.appendHeaders(attrs, rq.Header)
+	attrs = l.appendBody(attrs, body)
+
+	l.log(rq, l.level, l.requestMsg, attrs)
+}
+
+// DumpResponse is a httpdump.DumpResponseFunc that logs response.
+func (l *Logger) DumpResponse(rp *http.Response, body []byte, duration time.Duration) {
+	level := l.responseLevel(rp.StatusCode)
+
+	ctx := rp.Request.Context()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 7)
+	attrs = l.appendRequest(attrs, rp.Request)
+	attrs = append(attrs, slog.Int("status", rp.StatusCode))
+	attrs = l.appendHeaders(attrs, rp.Header)
+	attrs = l.appendBody(attrs, body)
+	attrs = append(attrs, l.duration(duration))
+
+	l.log(rp.Request, level, l.responseMsg, attrs)
+}
+
+func (l *Logger) log(r *http.Request, level slog.Level, msg string, attrs []slog.Attr) {
+	if l.group != "" {
+		attrs = []slog.Attr{{Key: l.group, Value: slog.GroupValue(attrs...)}}
+	}
+
+	l.logger.LogAttrs(r.Context(), level, msg, attrs...)
+}
+
+func (l *Logger) responseLevel(status int) slog.Level {
+	if class := status / 100; class > 0 && class < len(l.statusLevels) && l.statusLevels[class] != nil {
+		return *l.statusLevels[class]
+	}
+
+	return l.level
+}
+
+func (l *Logger) appendRequest(attrs []slog.Attr, r *http.Request) []slog.Attr {
+	if id := l.requestID(r); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+
+	return append(attrs,
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()))
+}
+
+func (l *Logger) appendHeaders(attrs []slog.Attr, h http.Header) []slog.Attr {
+	var ha []slog.Attr
+
+	if l.headers == nil {
+		keys := make([]string, 0, len(h))
+		for k := range h {
+			keys = append(keys, k)
+		}
+		// sorted keys make log records stable
+		sort.Strings(keys)
+
+		ha = make([]slog.Attr, 0, len(keys))
+		for _, k := range keys {
+			ha = append(ha, headerAttr(k, h[k]))
+		}
+	} else {
+		for _, k := range l.headers {
+			if vs, ok := h[k]; ok {
+				ha = append(ha, headerAttr(k, vs))
+			}
+		}
+	}
+
+	if len(ha) == 0 {
+		return attrs
+	}
+
+	return append(attrs, slog.Attr{Key: "headers", Value: slog.GroupValue(ha...)})
+}
+
+func headerAttr(k string, vs []string) slog.Attr {
+	if len(vs) == 1 {
+		return slog.String(k, vs[0])
+	}
+	return slog.Any(k, vs)
+}
+
+func (l *Logger) appendBody(attrs []slog.Attr, body []byte) []slog.Attr {
+	switch l.bodyPolicy {
+	case BodyBase64:
+		return append(attrs, slog.String("body", base64.StdEncoding.EncodeToString(body)))
+	case BodyJSON:
+		if len(body) > 0 && json.Valid(body) {
+			// body is reused by middleware after dump, so it is copied
+			return append(attrs, slog.Any("body", rawJSON(append([]byte(nil), body...))))
+		}
+	}
+
+	return append(attrs, slog.String("body", string(body)))
+}
+
+func (l *Logger) duration(d time.Duration) slog.Attr {
+	if l.durationUnit == 0 {
+		return slog.Duration("duration", d)
+	}
+
+	return slog.Float64("duration", float64(d)/float64(l.durationUnit))
+}
+
+// rawJSON is embedded as is by JSON handlers and logged as string by text handlers.
+type rawJSON []byte
+
+func (j rawJSON) MarshalJSON() ([]byte, error) {
+	return j, nil
+}
+
+func (j rawJSON) String() string {
+	return string(j)
+}
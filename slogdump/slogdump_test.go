@@ -0,0 +1,114 @@
+package slogdump_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/slogdump"
+)
+
+func TestLogger(t *testing.T) {
+	buff := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buff, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	dumpReq, dumpResp := slogdump.Funcs(
+		logger,
+		slogdump.WithStatusLevel(5, slog.LevelError),
+		slogdump.WithGroup("http"),
+		slogdump.WithHeaders("content-type"),
+		slogdump.WithBodyPolicy(slogdump.BodyJSON),
+		slogdump.WithDurationUnit(time.Millisecond),
+	)
+
+	m := httpdump.NewMiddleware(dumpReq, dumpResp)
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpdump.MimeTextPlain)
+		w.Header().Set("X-Secret", "1")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("failed"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/call_me", strings.NewReader(`{"a":1}`))
+	req.Header.Set("Content-Type", httpdump.MimeApplicationJSON)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	type record struct {
+		Level string
+		Msg   string
+		HTTP  struct {
+			RequestID string            `json:"request_id"`
+			Method    string            `json:"method"`
+			URL       string            `json:"url"`
+			Status    int               `json:"status"`
+			Headers   map[string]string `json:"headers"`
+			Body      json.RawMessage   `json:"body"`
+			Duration  *float64          `json:"duration"`
+		} `json:"http"`
+	}
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %s", len(lines), buff.String())
+	}
+
+	var rq, rp record
+	noerr(t, json.Unmarshal([]byte(lines[0]), &rq))
+	noerr(t, json.Unmarshal([]byte(lines[1]), &rp))
+
+	if rq.Level != "DEBUG" || rq.Msg != slogdump.DefaultRequestMessage ||
+		rq.HTTP.Method != http.MethodPost || rq.HTTP.URL != "/call_me" {
+		t.Errorf("Unexpected request record %s", lines[0])
+	}
+
+	if string(rq.HTTP.Body) != `{"a":1}` {
+		t.Errorf("Expected JSON body to be embedded, got %s", rq.HTTP.Body)
+	}
+
+	if rp.Level != "ERROR" || rp.HTTP.Status != http.StatusInternalServerError || rp.HTTP.Duration == nil {
+		t.Errorf("Unexpected response record %s", lines[1])
+	}
+
+	if string(rp.HTTP.Body) != `"failed"` {
+		t.Errorf("Expected not JSON body to be logged as string, got %s", rp.HTTP.Body)
+	}
+
+	if len(rp.HTTP.Headers) != 1 || rp.HTTP.Headers["Content-Type"] != httpdump.MimeTextPlain {
+		t.Errorf("Expected only allowed headers, got %v", rp.HTTP.Headers)
+	}
+
+	if rq.HTTP.RequestID == "" || rq.HTTP.RequestID != rp.HTTP.RequestID {
+		t.Errorf("Expected the same request id, got %q and %q", rq.HTTP.RequestID, rp.HTTP.RequestID)
+	}
+}
+
+func TestLogger_Base64(t *testing.T) {
+	buff := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buff, nil))
+
+	l := slogdump.New(logger, slogdump.WithLevel(slog.LevelInfo), slogdump.WithBodyPolicy(slogdump.BodyBase64))
+	l.DumpRequest(httptest.NewRequest(http.MethodGet, "/", http.NoBody), []byte{0xff, 0x00})
+
+	if !strings.Contains(buff.String(), `body="/wA="`) {
+		t.Errorf("Expected base64 body, got %s", buff.String())
+	}
+
+	if strings.Contains(buff.String(), "request_id") {
+		t.Errorf("Expected no request id for request not handled by middleware, got %s", buff.String())
+	}
+}
+
+func noerr(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
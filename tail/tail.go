@@ -0,0 +1,314 @@
+// Package tail streams exchanges captured by httpdump middleware to subscribers
+// as Server-Sent Events or newline-delimited JSON.
+package tail
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/replay"
+	"github.com/hummerd/httpdump/ring"
+)
+
+const (
+	// DefaultBufferSize is a default number of entries buffered for each subscriber.
+	DefaultBufferSize = 64
+	// DefaultHeartbeat is a default interval of SSE keep-alive comments.
+	DefaultHeartbeat = 15 * time.Second
+
+	// MimeEventStream is a content type of Server-Sent Events stream.
+	MimeEventStream = "text/event-stream"
+	// MimeNDJSON is a content type of newline-delimited JSON stream.
+	MimeNDJSON = "application/x-ndjson"
+)
+
+// Option is a broadcaster option that allows to override default broadcaster settings.
+type Option func(*Broadcaster)
+
+// WithBufferSize creates a new option that sets number of entries buffered
+// for each subscriber, entries are dropped when subscriber buffer is full.
+func WithBufferSize(size int) Option {
+	return func(b *Broadcaster) {
+		b.bufferSize = size
+	}
+}
+
+// WithHeartbeat creates a new option that sets interval of SSE keep-alive comments,
+// zero interval disables them.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(b *Broadcaster) {
+		b.heartbeat = interval
+	}
+}
+
+// Broadcaster sends dumped exchanges to all subscribers. Publishing never blocks,
+// entries are dropped for subscribers that do not keep up.
+// Safe to use from multiple goroutines.
+type Broadcaster struct {
+	mu         sync.Mutex
+	subs       map[*Subscription]struct{}
+	count      atomic.Int32
+	dropped    atomic.Uint64
+	bufferSize int
+	heartbeat  time.Duration
+}
+
+// Subscription receives entries matching its query.
+type Subscription struct {
+	b       *Broadcaster
+	q       *ring.Query
+	ch      chan ring.Entry
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// New creates a new broadcaster.
+func New(opts ...Option) *Broadcaster {
+	b := &Broadcaster{
+		subs:       make(map[*Subscription]struct{}),
+		bufferSize: DefaultBufferSize,
+		heartbeat:  DefaultHeartbeat,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// DumpExchange is a httpdump.DumpExchangeFunc that publishes exchange,
+// exchange is not copied if there are no subscribers.
+func (b *Broadcaster) DumpExchange(ex *httpdump.Exchange) {
+	if b.count.Load() == 0 {
+		return
+	}
+
+	b.Publish(ring.Entry{
+		ID:     httpdump.RequestID(ex.Request),
+		Record: replay.NewRecord(ex),
+	})
+}
+
+// Publish sends entry to subscribers with matching query.
+func (b *Broadcaster) Publish(e ring.Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subs {
+		if !s.q.Match(&e) {
+			continue
+		}
+
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe creates a new subscription for entries matching query,
+// Limit of query is not used. Subscription must be closed when it is not needed.
+func (b *Broadcaster) Subscribe(q *ring.Query) *Subscription {
+	s := &Subscription{
+		b:  b,
+		q:  q,
+		ch: make(chan ring.Entry, b.bufferSize),
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	b.count.Add(1)
+
+	return s
+}
+
+// Subscribers returns number of active subscriptions.
+func (b *Broadcaster) Subscribers() int {
+	return int(b.count.Load())
+}
+
+// Dropped returns number of entries dropped for all subscribers.
+func (b *Broadcaster) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Entries returns channel of entries, it is closed when subscription is closed.
+func (s *Subscription) Entries() <-chan ring.Entry {
+	return s.ch
+}
+
+// Dropped returns number of entries dropped because subscriber was too slow.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close removes subscription from broadcaster. Safe to call multiple times.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.b.mu.Lock()
+		delete(s.b.subs, s)
+		close(s.ch)
+		s.b.mu.Unlock()
+
+		s.b.count.Add(-1)
+	})
+}
+
+// ServeHTTP streams entries until client disconnects, entries are filtered by
+// query parameters (see ring.Handler), stream ends after limit entries if limit is set.
+// Stream is sent as newline-delimited JSON if format=ndjson parameter is set
+// or NDJSON is accepted by client, otherwise as Server-Sent Events.
+// When entries are dropped, subscriber gets "dropped" event (or JSON line in NDJSON)
+// with a total number of dropped entries.
+func (b *Broadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := ring.ParseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var st stream = &sseStream{w: w}
+	if wantsNDJSON(r) {
+		st = &ndjsonStream{enc: json.NewEncoder(w)}
+	}
+
+	// client gets all entries published after response header is received
+	s := b.Subscribe(q)
+	defer s.Close()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", st.contentType())
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	var heartbeat <-chan time.Time
+	if b.heartbeat > 0 {
+		t := time.NewTicker(b.heartbeat)
+		defer t.Stop()
+		heartbeat = t.C
+	}
+
+	var dropped uint64
+	sent := 0
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat:
+			err = st.heartbeat()
+		case e, ok := <-s.Entries():
+			if !ok {
+				return
+			}
+
+			if d := s.Dropped(); d != dropped {
+				dropped = d
+				if err = st.dropped(d); err != nil {
+					return
+				}
+			}
+
+			err = st.entry(&e)
+			sent++
+		}
+
+		if err != nil {
+			return
+		}
+
+		if err = rc.Flush(); err != nil {
+			return
+		}
+
+		if q.Limit > 0 && sent >= q.Limit {
+			return
+		}
+	}
+}
+
+func wantsNDJSON(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "ndjson"
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), MimeNDJSON)
+}
+
+type stream interface {
+	contentType() string
+	entry(e *ring.Entry) error
+	dropped(n uint64) error
+	heartbeat() error
+}
+
+type sseStream struct {
+	w http.ResponseWriter
+}
+
+func (s *sseStream) contentType() string {
+	return MimeEventStream
+}
+
+func (s *sseStream) entry(e *ring.Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	// JSON has no raw new lines, so it always fits single data field
+	_, err = fmt.Fprintf(s.w, "id: %s\ndata: %s\n\n", e.ID, data)
+	return err
+}
+
+func (s *sseStream) dropped(n uint64) error {
+	_, err := fmt.Fprintf(s.w, "event: dropped\ndata: %d\n\n", n)
+	return err
+}
+
+func (s *sseStream) heartbeat() error {
+	_, err := fmt.Fprint(s.w, ": heartbeat\n\n")
+	return err
+}
+
+type ndjsonStream struct {
+	enc *json.Encoder
+}
+
+func (s *ndjsonStream) contentType() string {
+	return MimeNDJSON
+}
+
+func (s *ndjsonStream) entry(e *ring.Entry) error {
+	return s.enc.Encode(e)
+}
+
+func (s *ndjsonStream) dropped(n uint64) error {
+	return s.enc.Encode(struct {
+		Dropped uint64 `json:"dropped"`
+	}{n})
+}
+
+// heartbeat is not sent in NDJSON stream, empty lines are not valid NDJSON
+func (s *ndjsonStream) heartbeat() error {
+	return nil
+}
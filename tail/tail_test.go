@@ -0,0 +1,150 @@
+package tail_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+	"github.com/hummerd/httpdump/replay"
+	"github.com/hummerd/httpdump/ring"
+	"github.com/hummerd/httpdump/tail"
+)
+
+func TestBroadcaster_SlowSubscriber(t *testing.T) {
+	b := tail.New(tail.WithBufferSize(1))
+
+	all := b.Subscribe(&ring.Query{})
+	defer all.Close()
+
+	posts := b.Subscribe(&ring.Query{Method: http.MethodPost})
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodGet} {
+		b.Publish(ring.Entry{Record: replay.Record{Method: method}})
+	}
+
+	if all.Dropped() != 2 || posts.Dropped() != 0 || b.Dropped() != 2 {
+		t.Errorf("Unexpected dropped entries %d, %d, %d", all.Dropped(), posts.Dropped(), b.Dropped())
+	}
+
+	if e := <-posts.Entries(); e.Method != http.MethodPost {
+		t.Errorf("Expected POST entry, got %s", e.Method)
+	}
+
+	posts.Close()
+	posts.Close()
+
+	if _, ok := <-posts.Entries(); ok {
+		t.Errorf("Expected entries channel to be closed")
+	}
+
+	if b.Subscribers() != 1 {
+		t.Errorf("Expected 1 subscriber, got %d", b.Subscribers())
+	}
+}
+
+func TestBroadcaster_ServeHTTP(t *testing.T) {
+	b := tail.New()
+
+	m := httpdump.NewMiddleware(nil, nil, httpdump.WithDumpExchange(b.DumpExchange))
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	tests := []struct {
+		query string
+		check func(t *testing.T, resp *http.Response)
+	}{
+		{
+			query: "?status=5xx&limit=2&format=ndjson",
+			check: func(t *testing.T, resp *http.Response) {
+				if ct := resp.Header.Get("Content-Type"); ct != tail.MimeNDJSON {
+					t.Errorf("Unexpected content type %s", ct)
+				}
+
+				dec := json.NewDecoder(resp.Body)
+				for i := 0; i < 2; i++ {
+					var e ring.Entry
+					noerr(t, dec.Decode(&e))
+
+					if e.StatusCode != http.StatusInternalServerError || e.ID == "" {
+						t.Errorf("Unexpected entry %+v", e)
+					}
+				}
+			},
+		},
+		{
+			query: "?path=^/ok&limit=1",
+			check: func(t *testing.T, resp *http.Response) {
+				if ct := resp.Header.Get("Content-Type"); ct != tail.MimeEventStream {
+					t.Errorf("Unexpected content type %s", ct)
+				}
+
+				sc := bufio.NewScanner(resp.Body)
+
+				var lines []string
+				for sc.Scan() {
+					lines = append(lines, sc.Text())
+				}
+
+				if len(lines) != 3 || !strings.HasPrefix(lines[0], "id: ") ||
+					!strings.HasPrefix(lines[1], "data: {") || !strings.Contains(lines[1], `"url":"/ok"`) {
+					t.Errorf("Unexpected event %q", lines)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.query)
+		noerr(t, err)
+
+		waitSubscribers(t, b, 1)
+
+		for _, p := range []string{"/ok", "/fail", "/ok", "/fail"} {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+		}
+
+		tt.check(t, resp)
+		resp.Body.Close()
+
+		waitSubscribers(t, b, 0)
+	}
+
+	resp, err := http.Get(srv.URL + "?status=x")
+	noerr(t, err)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected invalid query to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func waitSubscribers(t *testing.T, b *tail.Broadcaster, n int) {
+	t.Helper()
+
+	for i := 0; i < 100 && b.Subscribers() != n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if b.Subscribers() != n {
+		t.Fatalf("Expected %d subscribers, got %d", n, b.Subscribers())
+	}
+}
+
+func noerr(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
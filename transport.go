@@ -0,0 +1,280 @@
+package httpdump
+
+import (
+	"bytes"
+	stdio "io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hummerd/httpdump/io"
+)
+
+// Transport is an http.RoundTripper that dumps outgoing requests and
+// incoming responses. It shares dump functions, filters and body limit
+// with the Middleware it was created from.
+type Transport struct {
+	// Base is the underlying round tripper, http.DefaultTransport is used if nil.
+	Base http.RoundTripper
+
+	m *Middleware
+}
+
+// NewTransport creates a new transport that dumps client requests and responses.
+// It accepts the same options as NewMiddleware, so one set of options can be
+// used to configure both inbound and outbound capture.
+//
+// Request body prefix is read before request is sent. Response body prefix is
+// captured while client reads it, so streamed responses are not delayed, and
+// response is dumped when prefix is captured, body is read to the end or closed.
+// Response that is neither read nor closed is not dumped. Response to protocol
+// upgrade is dumped without body, since its body is a connection.
+func NewTransport(
+	base http.RoundTripper,
+	dumpRequest DumpRequestFunc,
+	dumpResponse DumpResponseFunc,
+	opts ...Option,
+) *Transport {
+	m := NewMiddleware(dumpRequest, dumpResponse, opts...)
+	return m.Transport(base)
+}
+
+// Transport creates a new transport that uses middleware settings.
+// Enabled state is shared between middleware and transport.
+func (m *Middleware) Transport(base http.RoundTripper) *Transport {
+	return &Transport{
+		Base: base,
+		m:    m,
+	}
+}
+
+// Enabled returns transport enabled state. Safe to call from multiple goroutines.
+func (t *Transport) Enabled() bool {
+	return t.m.Enabled()
+}
+
+// SetEnabled sets transport enabled state. Safe to call from multiple goroutines.
+func (t *Transport) SetEnabled(v bool) {
+	t.m.SetEnabled(v)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	m := t.m
+
+	// the same settings are used for the whole request
+	cfg := m.config.Load()
+
+	start := time.Now()
+
+	// activations dump matching requests even if transport is disabled
+	activation := cfg.activation(r, start)
+	if !cfg.enabled && activation == "" {
+		return base.RoundTrip(r)
+	}
+
+	// RoundTripper must not modify request,
+	// so all changes are made to a shallow copy
+	st := newRequestState(start)
+	st.activation = activation
+	st.setRequestBody(r.ContentLength, false)
+	r = withRequestState(r, st)
+
+	dumpReq, dumpReqBody := m.needDumpRequest(r, cfg)
+
+	var (
+		reqBody      []byte
+		reqEnc       *BodyEncoding
+		reqBytes     = r.ContentLength
+		reqTruncated bool
+		dr           *http.Request
+	)
+
+	if dumpReq {
+		var cr *io.PrefixReader
+
+		if dumpReqBody && r.Body != nil && r.Body != http.NoBody {
+			// transport may close request body from another goroutine
+			// while it is being read, so reader is not pooled
+			cr, _ = io.NewPrefixReader(nil, cfg.bodySize)
+
+			// it's ok to ignore error here
+			// further call to cr.Read() will return that error to transport
+			_ = cr.Reset(r.Body)
+
+			r.Body = cr
+
+			reqBody, reqEnc = m.decodedBody(r.Header, cr.Prefix(), cfg.bodySize)
+			reqBody = m.dumpedBody(r.Header, reqBody)
+		}
+
+		reqBytes, reqTruncated = requestBodySize(r, cr, 0, cfg.bodySize)
+		st.setRequestBody(reqBytes, reqTruncated)
+
+		dr = m.dumpedRequest(r)
+
+		if m.dumpRequest != nil {
+			m.dispatch(func() {
+				m.dumpRequest(dr, reqBody)
+			})
+		}
+	}
+
+	resp, err := base.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+
+	var dumpResp, dumpRespBody bool
+	if m.needDumpResponse() {
+		dumpResp, dumpRespBody = cfg.responsePassed(r, resp.Header, resp.StatusCode)
+	}
+
+	m.countFiltered(dumpReq, dumpResp)
+
+	if !dumpResp {
+		return resp, nil
+	}
+
+	if dr == nil {
+		dr = m.dumpedRequest(r)
+	}
+
+	dresp := m.dumpedResponse(resp, dr)
+
+	// response is dumped when its body prefix is captured
+	dump := func(prefix []byte, eof bool, read int64) {
+		duration := time.Since(start)
+
+		respBody, respEnc := m.decodedBody(dresp.Header, prefix, cfg.bodySize)
+		respBody = m.dumpedBody(dresp.Header, respBody)
+
+		respBytes := resp.ContentLength
+		if eof {
+			respBytes = read
+		}
+
+		respTruncated := !eof && prefixTruncated(resp.ContentLength, len(prefix), cfg.bodySize)
+		st.responseTruncated.Store(respTruncated)
+
+		if m.dumpResponse != nil {
+			rp := *dresp
+			rp.Body = stdio.NopCloser(bytes.NewReader(respBody))
+
+			m.dispatch(func() {
+				m.dumpResponse(&rp, respBody, duration)
+			})
+		}
+
+		if (dumpReq || keptUnsampled(r)) && m.dumpExchange != nil {
+			// request body is streamed after it is dumped,
+			// so only its declared length or prefix size is known
+			ex := &Exchange{
+				Request:           dr,
+				RequestBody:       reqBody,
+				StatusCode:        resp.StatusCode,
+				ResponseHeader:    dresp.Header,
+				ResponseBody:      respBody,
+				Start:             start,
+				Duration:          duration,
+				RequestBytes:      reqBytes,
+				RequestTruncated:  reqTruncated,
+				ResponseBytes:     respBytes,
+				ResponseTruncated: respTruncated,
+				RequestEncoding:   reqEnc,
+				ResponseEncoding:  respEnc,
+			}
+			m.dispatch(func() {
+				m.dumpExchange(ex)
+			})
+		}
+	}
+
+	if !dumpRespBody || resp.Body == nil || resp.Body == http.NoBody {
+		dump(nil, false, 0)
+		return resp, nil
+	}
+
+	if _, ok := resp.Body.(stdio.Writer); ok {
+		// body of switched protocol is a connection that client writes to,
+		// it is not wrapped, so its prefix is not captured
+		dump(nil, false, 0)
+		return resp, nil
+	}
+
+	resp.Body = &responseBody{
+		ReadCloser: resp.Body,
+		limit:      cfg.bodySize,
+		dump:       dump,
+	}
+
+	return resp, nil
+}
+
+// prefixTruncated reports whether body of declared size is longer than captured prefix,
+// body of unknown size is considered truncated if prefix reaches dumped body limit.
+func prefixTruncated(size int64, prefix, limit int) bool {
+	if size < 0 {
+		return prefix >= limit
+	}
+
+	return size > int64(prefix)
+}
+
+// responseBody captures response body prefix while client reads it, so streamed
+// responses are not delayed. Response is dumped once when prefix is captured,
+// body is read to the end or closed. Client may close body from another goroutine,
+// so captured prefix is guarded by mutex.
+type responseBody struct {
+	stdio.ReadCloser
+	limit int
+	dump  func(prefix []byte, eof bool, read int64)
+
+	mu     sync.Mutex
+	prefix []byte
+	read   int64
+	dumped bool
+}
+
+func (rb *responseBody) Read(p []byte) (int, error) {
+	n, err := rb.ReadCloser.Read(p)
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.read += int64(n)
+
+	if !rb.dumped {
+		rb.prefix = append(rb.prefix, p[:min(n, rb.limit-len(rb.prefix))]...)
+
+		if err != nil || len(rb.prefix) == rb.limit {
+			rb.dumpLocked(err == stdio.EOF)
+		}
+	}
+
+	return n, err
+}
+
+func (rb *responseBody) Close() error {
+	err := rb.ReadCloser.Close()
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if !rb.dumped {
+		rb.dumpLocked(false)
+	}
+
+	return err
+}
+
+func (rb *responseBody) dumpLocked(eof bool) {
+	rb.dumped = true
+	// prefix is not changed after dump, so it is passed without copy
+	rb.dump(rb.prefix, eof, rb.read)
+}
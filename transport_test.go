@@ -0,0 +1,223 @@
+package httpdump_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestTransport_FullDump(t *testing.T) {
+	reqBody := `{ "some": "json" }`
+	respBody := "Welcome!"
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		noerr(t, err)
+
+		if string(b) != reqBody {
+			t.Errorf("Expected server to get body %q, got %q", reqBody, b)
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(respBody))
+	}))
+	defer s.Close()
+
+	result := &httpDumpResult{}
+
+	tr := httpdump.NewTransport(
+		s.Client().Transport,
+		func(rq *http.Request, body []byte) {
+			result.reqDumped = true
+			result.req = rq
+			result.reqBody = append([]byte(nil), body...)
+		},
+		func(rp *http.Response, body []byte, duration time.Duration) {
+			result.respDumped = true
+			result.resp = rp
+			result.respBody = append([]byte(nil), body...)
+			result.respDuration = duration
+		},
+	)
+
+	c := &http.Client{Transport: tr}
+
+	resp, err := c.Post(s.URL+"/somepath", httpdump.MimeApplicationJSON, strings.NewReader(reqBody))
+	noerr(t, err)
+
+	gotBody, err := io.ReadAll(resp.Body)
+	noerr(t, err)
+	noerr(t, resp.Body.Close())
+
+	if string(gotBody) != respBody {
+		t.Errorf("Expected client to get body %q, got %q", respBody, gotBody)
+	}
+
+	if !result.reqDumped || string(result.reqBody) != reqBody {
+		t.Errorf("Expected request body %q to be dumped, got %v %q", reqBody, result.reqDumped, result.reqBody)
+	}
+
+	if result.req.Method != http.MethodPost || result.req.URL.Path != "/somepath" {
+		t.Errorf("Unexpected dumped request %v %v", result.req.Method, result.req.URL)
+	}
+
+	if !result.respDumped || string(result.respBody) != respBody {
+		t.Errorf("Expected response body %q to be dumped, got %v %q", respBody, result.respDumped, result.respBody)
+	}
+
+	if result.resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected response status code %v, got %v", http.StatusOK, result.resp.StatusCode)
+	}
+
+	if result.respDuration == 0 {
+		t.Errorf("Expected response duration > 0, got %v", result.respDuration)
+	}
+}
+
+func TestTransport_SharedOptions(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("0123456789"))
+	}))
+	defer s.Close()
+
+	m, result := newMiddleware(
+		true,
+		true,
+		[]httpdump.Option{
+			httpdump.WithRequestPathFilter(regexp.MustCompile("skipped")),
+		})
+
+	c := &http.Client{Transport: m.Transport(s.Client().Transport)}
+
+	resp, err := c.Get(s.URL + "/skipped")
+	noerr(t, err)
+	resp.Body.Close()
+
+	if result.reqDumped {
+		t.Errorf("Expected request to be filtered by path")
+	}
+
+	if !result.respDumped || result.respBody != nil {
+		t.Errorf("Expected response without body to be dumped, got %v %q", result.respDumped, result.respBody)
+	}
+
+	m.SetEnabled(false)
+	result.respDumped = false
+
+	resp, err = c.Get(s.URL + "/other")
+	noerr(t, err)
+	resp.Body.Close()
+
+	if result.reqDumped || result.respDumped {
+		t.Errorf("Expected nothing to be dumped by disabled transport")
+	}
+}
+
+func TestTransport_StreamedResponse(t *testing.T) {
+	release := make(chan struct{})
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpdump.MimeTextPlain)
+		w.Write([]byte("line 1\n"))
+		w.(http.Flusher).Flush()
+
+		<-release
+		w.Write([]byte("line 2\n"))
+	}))
+	defer s.Close()
+	defer close(release)
+
+	m, result := newMiddleware(false, true, []httpdump.Option{httpdump.WithLimitedBody(10)})
+
+	c := &http.Client{Transport: m.Transport(s.Client().Transport)}
+
+	start := time.Now()
+
+	resp, err := c.Get(s.URL)
+	noerr(t, err)
+	defer resp.Body.Close()
+
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Expected response to be returned before body is streamed, took %v", d)
+	}
+
+	if result.respDumped {
+		t.Errorf("Expected response to be dumped when body prefix is read")
+	}
+
+	line := make([]byte, 7)
+	_, err = io.ReadFull(resp.Body, line)
+	noerr(t, err)
+
+	release <- struct{}{}
+
+	rest, err := io.ReadAll(resp.Body)
+	noerr(t, err)
+
+	if string(line)+string(rest) != "line 1\nline 2\n" {
+		t.Errorf("Unexpected client body %q", string(line)+string(rest))
+	}
+
+	if !result.respDumped || string(result.respBody) != "line 1\nlin" {
+		t.Errorf("Expected response body prefix to be dumped, got %v %q", result.respDumped, result.respBody)
+	}
+}
+
+func TestTransport_SwitchingProtocols(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		noerr(t, err)
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Connection: Upgrade\r\nUpgrade: echo\r\nContent-Type: text/plain\r\n\r\n")
+		noerr(t, rw.Flush())
+
+		line, err := rw.ReadString('\n')
+		noerr(t, err)
+
+		rw.WriteString(line)
+		noerr(t, rw.Flush())
+	}))
+	defer s.Close()
+
+	m, result := newMiddleware(false, true, nil)
+
+	c := &http.Client{Transport: m.Transport(s.Client().Transport)}
+
+	req, err := http.NewRequest(http.MethodGet, s.URL, http.NoBody)
+	noerr(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+
+	resp, err := c.Do(req)
+	noerr(t, err)
+	defer resp.Body.Close()
+
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if resp.StatusCode != http.StatusSwitchingProtocols || !ok {
+		t.Fatalf("Expected upgraded connection to be writable, got %d %T", resp.StatusCode, resp.Body)
+	}
+
+	_, err = conn.Write([]byte("ping\n"))
+	noerr(t, err)
+
+	line := make([]byte, 5)
+	_, err = io.ReadFull(conn, line)
+	noerr(t, err)
+
+	if string(line) != "ping\n" {
+		t.Errorf("Unexpected echo %q", line)
+	}
+
+	if !result.respDumped || result.resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Expected switching protocols response to be dumped")
+	}
+}
@@ -0,0 +1,194 @@
+package httpdump
+
+import (
+	"bufio"
+	stdio "io"
+	"net"
+	"net/http"
+)
+
+// responseWriter is implemented by every writer passed to wrapped handler,
+// Unwrap is used by http.ResponseController.
+type responseWriter interface {
+	http.ResponseWriter
+	Unwrap() http.ResponseWriter
+}
+
+// wrapResponseWriter returns writer that implements exactly those optional
+// interfaces (http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom)
+// that are implemented by underlying writer.
+func wrapResponseWriter(cw *cachedWriter) http.ResponseWriter {
+	var (
+		_, f  = cw.w.(http.Flusher)
+		_, h  = cw.w.(http.Hijacker)
+		_, p  = cw.w.(http.Pusher)
+		_, rf = cw.w.(stdio.ReaderFrom)
+	)
+
+	var kind int
+	if f {
+		kind |= 1
+	}
+	if h {
+		kind |= 2
+	}
+	if p {
+		kind |= 4
+	}
+	if rf {
+		kind |= 8
+	}
+
+	switch kind {
+	case 1:
+		return struct {
+			responseWriter
+			http.Flusher
+		}{cw, cw}
+	case 2:
+		return struct {
+			responseWriter
+			http.Hijacker
+		}{cw, cw}
+	case 1 | 2:
+		return struct {
+			responseWriter
+			http.Flusher
+			http.Hijacker
+		}{cw, cw, cw}
+	case 4:
+		return struct {
+			responseWriter
+			http.Pusher
+		}{cw, cw}
+	case 1 | 4:
+		return struct {
+			responseWriter
+			http.Flusher
+			http.Pusher
+		}{cw, cw, cw}
+	case 2 | 4:
+		return struct {
+			responseWriter
+			http.Hijacker
+			http.Pusher
+		}{cw, cw, cw}
+	case 1 | 2 | 4:
+		return struct {
+			responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{cw, cw, cw, cw}
+	case 8:
+		return struct {
+			responseWriter
+			stdio.ReaderFrom
+		}{cw, cw}
+	case 1 | 8:
+		return struct {
+			responseWriter
+			http.Flusher
+			stdio.ReaderFrom
+		}{cw, cw, cw}
+	case 2 | 8:
+		return struct {
+			responseWriter
+			http.Hijacker
+			stdio.ReaderFrom
+		}{cw, cw, cw}
+	case 1 | 2 | 8:
+		return struct {
+			responseWriter
+			http.Flusher
+			http.Hijacker
+			stdio.ReaderFrom
+		}{cw, cw, cw, cw}
+	case 4 | 8:
+		return struct {
+			responseWriter
+			http.Pusher
+			stdio.ReaderFrom
+		}{cw, cw, cw}
+	case 1 | 4 | 8:
+		return struct {
+			responseWriter
+			http.Flusher
+			http.Pusher
+			stdio.ReaderFrom
+		}{cw, cw, cw, cw}
+	case 2 | 4 | 8:
+		return struct {
+			responseWriter
+			http.Hijacker
+			http.Pusher
+			stdio.ReaderFrom
+		}{cw, cw, cw, cw}
+	case 1 | 2 | 4 | 8:
+		return struct {
+			responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			stdio.ReaderFrom
+		}{cw, cw, cw, cw, cw}
+	default:
+		return struct {
+			responseWriter
+		}{cw}
+	}
+}
+
+// Unwrap returns underlying writer, it is used by http.ResponseController.
+func (cw *cachedWriter) Unwrap() http.ResponseWriter {
+	return cw.w
+}
+
+// Flush implements http.Flusher, it must be called only
+// if underlying writer implements http.Flusher.
+func (cw *cachedWriter) Flush() {
+	// headers are sent on flush, so it's the last chance to apply filters
+	cw.EnsureFilterPassed()
+
+	cw.w.(http.Flusher).Flush()
+}
+
+// Hijack implements http.Hijacker, it must be called only
+// if underlying writer implements http.Hijacker.
+func (cw *cachedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := cw.w.(http.Hijacker).Hijack()
+	if err == nil && cw.statusCode == 0 {
+		// response is written directly to the connection by handler,
+		// most of the time it is a protocol upgrade
+		cw.statusCode = http.StatusSwitchingProtocols
+	}
+
+	return conn, brw, err
+}
+
+// Push implements http.Pusher, it must be called only
+// if underlying writer implements http.Pusher.
+func (cw *cachedWriter) Push(target string, opts *http.PushOptions) error {
+	return cw.w.(http.Pusher).Push(target, opts)
+}
+
+// ReadFrom implements io.ReaderFrom, so underlying writer can use sendfile.
+func (cw *cachedWriter) ReadFrom(r stdio.Reader) (int64, error) {
+	cw.EnsureFilterPassed()
+
+	var (
+		n   int64
+		err error
+	)
+
+	if cw.dumpBody {
+		n, err = cw.PrefixWriter.ReadFrom(r)
+	} else {
+		// io.Copy uses underlying writer ReadFrom if there is one
+		n, err = stdio.Copy(cw.w, r)
+	}
+
+	cw.bytesWritten += n
+
+	return n, err
+}
@@ -0,0 +1,175 @@
+package httpdump_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hummerd/httpdump"
+)
+
+func TestMiddleware_Flush(t *testing.T) {
+	m, dump := newMiddleware(false, true, nil)
+
+	var flushed bool
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); ok {
+			t.Errorf("Writer must not implement http.Hijacker if recorder does not")
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("event"))
+
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("Writer must implement http.Flusher")
+		}
+		f.Flush()
+
+		flushed = true
+
+		err := http.NewResponseController(w).Flush()
+		noerr(t, err)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", http.NoBody))
+
+	if !flushed || !rec.Flushed {
+		t.Errorf("Expected response to be flushed")
+	}
+
+	if !dump.respDumped || string(dump.respBody) != "event" {
+		t.Errorf("Unexpected response dump %v %q", dump.respDumped, dump.respBody)
+	}
+}
+
+func TestMiddleware_Hijack(t *testing.T) {
+	dumped := make(chan int, 1)
+
+	m := httpdump.NewMiddleware(
+		nil,
+		func(rp *http.Response, _ []byte, _ time.Duration) {
+			dumped <- rp.StatusCode
+		})
+
+	s := httptest.NewServer(m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		noerr(t, err)
+		defer conn.Close()
+
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+	})))
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	noerr(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n"))
+	noerr(t, err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	noerr(t, err)
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Expected status %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+
+	select {
+	case status := <-dumped:
+		if status != http.StatusSwitchingProtocols {
+			t.Errorf("Expected hijacked response to be dumped with status %d, got %d", http.StatusSwitchingProtocols, status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Hijacked response is not dumped")
+	}
+}
+
+func TestMiddleware_ReadFrom(t *testing.T) {
+	body := strings.Repeat("0123456789", 200)
+
+	dumped := make(chan []byte, 1)
+
+	m := httpdump.NewMiddleware(
+		nil,
+		func(rp *http.Response, b []byte, _ time.Duration) {
+			dumped <- append([]byte(nil), b...)
+		},
+		httpdump.WithLimitedBody(10))
+
+	s := httptest.NewServer(m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(io.ReaderFrom); !ok {
+			t.Errorf("Writer must implement io.ReaderFrom")
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		// strings.Reader implements io.WriterTo, hide it,
+		// so io.Copy uses writer ReadFrom
+		io.Copy(w, io.LimitReader(strings.NewReader(body), int64(len(body))))
+	})))
+	defer s.Close()
+
+	resp, err := s.Client().Get(s.URL)
+	noerr(t, err)
+
+	b, err := io.ReadAll(resp.Body)
+	noerr(t, err)
+	resp.Body.Close()
+
+	if string(b) != body {
+		t.Errorf("Unexpected response body length %d", len(b))
+	}
+
+	if d := <-dumped; string(d) != body[:10] {
+		t.Errorf("Unexpected dumped body %q", d)
+	}
+}
+
+func TestMiddleware_SentHeaderSnapshot(t *testing.T) {
+	var ex *httpdump.Exchange
+
+	m, dump := newMiddleware(true, true, []httpdump.Option{
+		httpdump.WithDumpExchange(func(e *httpdump.Exchange) {
+			ex = e
+		}),
+	})
+
+	h := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("X-Request", "changed")
+		r.URL.Path = "/changed"
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Response", "sent")
+		w.WriteHeader(http.StatusOK)
+
+		w.Header().Set("X-Response", "changed")
+		w.Header().Set("X-After", "not sent")
+		w.Write([]byte("body"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader("body"))
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-Request", "sent")
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if dump.req.Header.Get("X-Request") != "sent" || dump.req.URL.Path != "/items" {
+		t.Errorf("Expected dumped request to be a copy, got %v %s", dump.req.Header, dump.req.URL)
+	}
+
+	if dump.resp.Header.Get("X-Response") != "sent" || dump.resp.Header.Get("X-After") != "" {
+		t.Errorf("Expected dumped response headers to be sent headers, got %v", dump.resp.Header)
+	}
+
+	if ex.ResponseHeader.Get("X-Response") != "sent" || ex.Request.Header.Get("X-Request") != "sent" {
+		t.Errorf("Unexpected exchange headers %v %v", ex.Request.Header, ex.ResponseHeader)
+	}
+}